@@ -0,0 +1,376 @@
+package cleanhttp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// binaryFormatVersion is bumped whenever the binary encoding changes.
+// Changes to the Rule struct are detected through ruleLayout instead.
+const binaryFormatVersion = 2
+
+// binaryMagic starts every binary ruleset
+const binaryMagic = "CHRB"
+
+var (
+	regexpType = reflect.TypeOf((*regexp.Regexp)(nil))
+	timeType   = reflect.TypeOf(time.Time{})
+	// ruleLayout describes the exported fields of Rule, so that rules
+	// written by a build with a different Rule are rejected
+	ruleLayout = layout(reflect.TypeOf(Rule{}), nil)
+)
+
+// CompileToBinary writes the compiled rules of the matcher in a compact
+// binary form that NewMatcherFromBinary loads without parsing JSON or
+// compiling the rules again. Only regexes are stored as source.
+func (m *Matcher) CompileToBinary(w io.Writer) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	buf := append([]byte(binaryMagic), byte(binaryFormatVersion))
+	buf = appendString(buf, ruleLayout)
+	buf = binary.AppendUvarint(buf, uint64(len(m.order)))
+	for _, provider := range m.order {
+		buf = appendString(buf, provider)
+		var err error
+		if buf, err = appendValue(buf, reflect.ValueOf(m.rules[provider])); err != nil {
+			return fmt.Errorf("encoding binary rule %s: %w", provider, err)
+		}
+	}
+	if _, err := w.Write(buf); err != nil {
+		return fmt.Errorf("writing binary rules: %w", err)
+	}
+	return nil
+}
+
+// NewMatcherFromBinary creates a Matcher from rules written by
+// CompileToBinary. Only the regexes are compiled on load.
+func NewMatcherFromBinary(r io.Reader, opts ...Option) (*Matcher, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading binary rules: %w", err)
+	}
+	if !strings.HasPrefix(string(data), binaryMagic) || len(data) == len(binaryMagic) {
+		return nil, errors.New("decoding binary rules: not a binary ruleset")
+	}
+	if version := data[len(binaryMagic)]; version != binaryFormatVersion {
+		return nil, fmt.Errorf("unsupported binary rules version %d", version)
+	}
+
+	d := &binaryDecoder{
+		data:    string(data[len(binaryMagic)+1:]),
+		fields:  make(map[reflect.Type][]int),
+		scratch: make(map[reflect.Type][2]reflect.Value),
+	}
+	if d.string() != ruleLayout && d.err == nil {
+		return nil, errors.New("binary rules were written by a different version of cleanhttp, compile them again")
+	}
+	count := d.uvarint()
+	rules := make(map[string]Rule, min(count, uint64(len(d.data))))
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		provider := d.string()
+		var rule Rule
+		d.value(reflect.ValueOf(&rule).Elem())
+		rules[provider] = rule.decoded()
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("decoding binary rules: %w", d.err)
+	}
+
+	m := emptyMatcher(opts)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for provider, rule := range rules {
+		m.setRule(provider, rule)
+	}
+	m.sortRules()
+	return m, nil
+}
+
+// decoded restores the unexported fields, which are not encoded
+func (r Rule) decoded() Rule {
+	r.needsFullBody = len(r.JSONFields) > 0 || slices.ContainsFunc(r.BodyRegex, endSensitive)
+	for i, group := range r.Groups {
+		r.Groups[i] = group.decoded()
+	}
+	if r.On404 != nil {
+		on404 := r.On404.decoded()
+		r.On404 = &on404
+	}
+	return r
+}
+
+// layout describes the exported fields of a type, recursively
+func layout(t reflect.Type, seen []reflect.Type) string {
+	if t == regexpType || t == timeType || slices.Contains(seen, t) {
+		return t.String()
+	}
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice:
+		return t.Kind().String() + "(" + layout(t.Elem(), seen) + ")"
+	case reflect.Map:
+		return "map(" + layout(t.Key(), seen) + "," + layout(t.Elem(), seen) + ")"
+	case reflect.Struct:
+		seen = append(seen, t)
+		var fields []string
+		for i := 0; i < t.NumField(); i++ {
+			if field := t.Field(i); field.IsExported() {
+				fields = append(fields, field.Name+" "+layout(field.Type, seen))
+			}
+		}
+		return t.String() + "{" + strings.Join(fields, ";") + "}"
+	}
+	return t.String()
+}
+
+// exportedFields returns the indexes of the exported fields of a struct
+func exportedFields(t reflect.Type) []int {
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
+
+// appendString appends a length prefixed string
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// appendValue appends the encoding of the exported fields of v. Nil
+// slices, maps and pointers are told apart from empty ones, and only
+// the fields of a struct that are not zero are written, each after its
+// position among the exported fields.
+func appendValue(buf []byte, v reflect.Value) ([]byte, error) {
+	switch v.Type() {
+	case regexpType:
+		if v.IsNil() {
+			return append(buf, 0), nil
+		}
+		return appendString(append(buf, 1), v.Interface().(*regexp.Regexp).String()), nil
+	case timeType:
+		data, err := v.Interface().(time.Time).MarshalBinary()
+		return appendString(buf, string(data)), err
+	}
+
+	var err error
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case reflect.Int, reflect.Int64:
+		return binary.AppendVarint(buf, v.Int()), nil
+	case reflect.Uint32:
+		return binary.AppendUvarint(buf, v.Uint()), nil
+	case reflect.Float64:
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(v.Float())), nil
+	case reflect.String:
+		return appendString(buf, v.String()), nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return append(buf, 0), nil
+		}
+		return appendValue(append(buf, 1), v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(buf, 0), nil
+		}
+		buf = binary.AppendUvarint(buf, uint64(v.Len())+1)
+		for i := 0; i < v.Len() && err == nil; i++ {
+			buf, err = appendValue(buf, v.Index(i))
+		}
+		return buf, err
+	case reflect.Map:
+		if v.IsNil() {
+			return append(buf, 0), nil
+		}
+		buf = binary.AppendUvarint(buf, uint64(v.Len())+1)
+		for iter := v.MapRange(); iter.Next() && err == nil; {
+			if buf, err = appendValue(buf, iter.Key()); err == nil {
+				buf, err = appendValue(buf, iter.Value())
+			}
+		}
+		return buf, err
+	case reflect.Struct:
+		for i, field := range exportedFields(v.Type()) {
+			if err == nil && !v.Field(field).IsZero() {
+				buf = binary.AppendUvarint(buf, uint64(i)+1)
+				buf, err = appendValue(buf, v.Field(field))
+			}
+		}
+		return append(buf, 0), err
+	}
+	return buf, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// binaryDecoder reads values written by appendValue, keeping the first
+// error. Decoded strings share the memory of data.
+type binaryDecoder struct {
+	data string
+	err  error
+	// fields caches the exported field indexes of each struct type
+	fields map[reflect.Type][]int
+	// scratch caches the key and value decoded into before being stored
+	// in a map of each type
+	scratch map[reflect.Type][2]reflect.Value
+}
+
+// fail records an error and stops decoding
+func (d *binaryDecoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+	d.data = ""
+}
+
+// uvarint reads a value written by binary.AppendUvarint
+func (d *binaryDecoder) uvarint() uint64 {
+	var value uint64
+	for i := 0; i < len(d.data) && i < binary.MaxVarintLen64; i++ {
+		b := d.data[i]
+		value |= uint64(b&0x7f) << (7 * i)
+		if b < 0x80 {
+			d.data = d.data[i+1:]
+			return value
+		}
+	}
+	d.fail(io.ErrUnexpectedEOF)
+	return 0
+}
+
+// varint reads a value written by binary.AppendVarint
+func (d *binaryDecoder) varint() int64 {
+	value := d.uvarint()
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+func (d *binaryDecoder) bytes(n uint64) string {
+	if n > uint64(len(d.data)) {
+		d.fail(io.ErrUnexpectedEOF)
+		return ""
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *binaryDecoder) string() string {
+	return d.bytes(d.uvarint())
+}
+
+// flag reads a byte written as 0 or 1
+func (d *binaryDecoder) flag() bool {
+	return d.bytes(1) == "\x01"
+}
+
+// length reads the length of a slice or map, ok is false when it is nil
+func (d *binaryDecoder) length() (n int, ok bool) {
+	encoded := d.uvarint()
+	if encoded == 0 || encoded-1 > uint64(len(d.data)) {
+		if encoded != 0 {
+			d.fail(io.ErrUnexpectedEOF)
+		}
+		return 0, false
+	}
+	return int(encoded - 1), true
+}
+
+// value decodes into v, which must be settable
+func (d *binaryDecoder) value(v reflect.Value) {
+	if d.err != nil {
+		return
+	}
+	switch v.Type() {
+	case regexpType:
+		if d.flag() {
+			source := d.string()
+			re, err := regexp.Compile(source)
+			if err != nil {
+				d.fail(fmt.Errorf("regex %q: %w", source, err))
+				return
+			}
+			v.Set(reflect.ValueOf(re))
+		}
+		return
+	case timeType:
+		if err := v.Addr().Interface().(*time.Time).UnmarshalBinary([]byte(d.string())); err != nil {
+			d.fail(err)
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(d.flag())
+	case reflect.Int, reflect.Int64:
+		v.SetInt(d.varint())
+	case reflect.Uint32:
+		v.SetUint(d.uvarint())
+	case reflect.Float64:
+		if b := d.bytes(8); b != "" {
+			v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64([]byte(b))))
+		}
+	case reflect.String:
+		v.SetString(d.string())
+	case reflect.Pointer:
+		if d.flag() {
+			v.Set(reflect.New(v.Type().Elem()))
+			d.value(v.Elem())
+		}
+	case reflect.Slice:
+		if n, ok := d.length(); ok {
+			v.Set(reflect.MakeSlice(v.Type(), n, n))
+			for i := 0; i < n; i++ {
+				d.value(v.Index(i))
+			}
+		}
+	case reflect.Map:
+		if n, ok := d.length(); ok {
+			v.Set(reflect.MakeMapWithSize(v.Type(), n))
+			// Rule has no map nested in a map of the same type, so the
+			// scratch values are never in use twice
+			scratch, ok := d.scratch[v.Type()]
+			if !ok {
+				scratch = [2]reflect.Value{reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()}
+				d.scratch[v.Type()] = scratch
+			}
+			key, elem := scratch[0], scratch[1]
+			for i := 0; i < n && d.err == nil; i++ {
+				key.SetZero()
+				elem.SetZero()
+				d.value(key)
+				d.value(elem)
+				v.SetMapIndex(key, elem)
+			}
+		}
+	case reflect.Struct:
+		fields, ok := d.fields[v.Type()]
+		if !ok {
+			fields = exportedFields(v.Type())
+			d.fields[v.Type()] = fields
+		}
+		for i := d.uvarint(); i != 0 && d.err == nil; i = d.uvarint() {
+			if i > uint64(len(fields)) {
+				d.fail(fmt.Errorf("field %d of %s out of range", i, v.Type()))
+				return
+			}
+			d.value(v.Field(fields[i-1]))
+		}
+	default:
+		d.fail(fmt.Errorf("unsupported type %s", v.Type()))
+	}
+}
@@ -0,0 +1,101 @@
+package cleanhttp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	jsonMatcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, jsonMatcher.CompileToBinary(&buf))
+	binaryMatcher, err := NewMatcherFromBinary(&buf)
+	require.NoError(t, err)
+
+	responses := []Response{
+		{StatusCode: 503, Headers: map[string]string{"Server": "cloudflare"}, Body: "error code: 1020"},
+		{StatusCode: 403, Headers: map[string]string{"Server": "CloudFront"}, Title: "ERROR: The request could not be satisfied", Body: "Generated by cloudfront (CloudFront)"},
+		{StatusCode: 200, Headers: map[string]string{"Server": "nginx"}},
+	}
+	for _, resp := range responses {
+		require.ElementsMatch(t, jsonMatcher.Match(resp), binaryMatcher.Match(resp))
+	}
+
+	// rate-limit rules are opt-in, so Match never reports them
+	limited := Response{
+		StatusCode: 429,
+		Headers:    map[string]string{"Server": "cloudflare", "Retry-After": "60"},
+		Body:       "<h1>Error 1015</h1><p>You are being rate limited</p>",
+	}
+	wantLimited, wantProvider := jsonMatcher.MatchRateLimit(limited)
+	require.True(t, wantLimited)
+	gotLimited, gotProvider := binaryMatcher.MatchRateLimit(limited)
+	require.True(t, gotLimited)
+	require.Equal(t, wantProvider, gotProvider)
+
+	require.Equal(t, jsonMatcher.order, binaryMatcher.order)
+	for provider, rule := range jsonMatcher.rules {
+		require.Equal(t, rule.needsFullBody, binaryMatcher.rules[provider].needsFullBody, provider)
+	}
+}
+
+func TestNewMatcherFromBinaryErrors(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, matcher.CompileToBinary(&buf))
+	data := buf.Bytes()
+
+	stale := bytes.Clone(data)
+	stale[len(binaryMagic)+4] ^= 0xff
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{name: "not binary", data: []byte("not binary"), want: "not a binary ruleset"},
+		{name: "version", data: []byte(binaryMagic + "\x01"), want: "unsupported binary rules version 1"},
+		{name: "layout", data: stale, want: "different version of cleanhttp"},
+		{name: "truncated", data: data[:len(data)/2], want: "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMatcherFromBinary(bytes.NewReader(tt.data))
+			require.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func BenchmarkNewMatcherJSON(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewMatcher(""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewMatcherBinary(b *testing.B) {
+	matcher, err := NewMatcher("")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := matcher.CompileToBinary(&buf); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewMatcherFromBinary(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package cleanhttp
+
+import (
+	"slices"
+	"strings"
+)
+
+// BlockPageHeuristics tunes the block page likelihood computed by
+// IsLikelyBlockPage
+type BlockPageHeuristics struct {
+	// Threshold is the minimum likelihood for a response to be reported
+	// as a block page
+	Threshold float64
+	// MaxBodyLength is the body length under which a page is considered short
+	MaxBodyLength int
+	// BlockStatuses are status codes typically used by block pages
+	BlockStatuses []int
+	// VendorTokens are lowercase tokens found on security vendor pages
+	VendorTokens []string
+	// AppMarkers are lowercase tokens found on real application pages
+	AppMarkers []string
+}
+
+// DefaultBlockPageHeuristics are the heuristics used unless overridden
+// with WithBlockPageHeuristics
+var DefaultBlockPageHeuristics = BlockPageHeuristics{
+	Threshold:     0.5,
+	MaxBodyLength: 8192,
+	BlockStatuses: []int{401, 403, 406, 429, 451, 503},
+	VendorTokens: []string{
+		"access denied", "request blocked", "you have been blocked", "ray id",
+		"incident id", "support id", "reference #", "captcha", "firewall",
+		"cloudflare", "akamai", "imperva", "incapsula", "sucuri", "barracuda",
+	},
+	AppMarkers: []string{
+		"<nav", "<footer", "traceback", "stack trace", "exception", "<form",
+		"login", "sign in", "debug",
+	},
+}
+
+// Weights of the individual block page signals
+const (
+	blockWeightWAFMatch     = 0.5
+	blockWeightVendorToken  = 0.3
+	blockWeightBlockStatus  = 0.2
+	blockWeightShortBody    = 0.1
+	blockWeightNoAppMarkers = 0.1
+)
+
+// IsLikelyBlockPage estimates whether the response is a WAF or security
+// vendor block page rather than an error page from the origin. It
+// returns whether the likelihood reaches the heuristics threshold, and
+// the likelihood between 0 and 1.
+func (m *Matcher) IsLikelyBlockPage(resp Response) (bool, float64) {
+	heuristics := m.blockPageHeuristics
+	body := strings.ToLower(resp.Body)
+	title := strings.ToLower(resp.Title)
+
+	var likelihood float64
+	if len(m.MatchCategory(resp, CategoryWAF)) > 0 {
+		likelihood += blockWeightWAFMatch
+	}
+	if slices.ContainsFunc(heuristics.VendorTokens, func(token string) bool {
+		return strings.Contains(body, token) || strings.Contains(title, token)
+	}) {
+		likelihood += blockWeightVendorToken
+	}
+	if slices.Contains(heuristics.BlockStatuses, resp.StatusCode) {
+		likelihood += blockWeightBlockStatus
+	}
+	if len(resp.Body) < heuristics.MaxBodyLength {
+		likelihood += blockWeightShortBody
+	}
+	if !slices.ContainsFunc(heuristics.AppMarkers, func(marker string) bool {
+		return strings.Contains(body, marker)
+	}) {
+		likelihood += blockWeightNoAppMarkers
+	}
+
+	likelihood = min(likelihood, 1)
+	return likelihood >= heuristics.Threshold, likelihood
+}
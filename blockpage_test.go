@@ -0,0 +1,47 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsLikelyBlockPage(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	cloudflareBlock := Response{
+		StatusCode: 403,
+		Headers:    map[string]string{"Server": "cloudflare"},
+		Title:      "Attention Required! | Cloudflare",
+		Body: `<html><head><title>Attention Required! | Cloudflare</title></head>
+<body><h1>Sorry, you have been blocked</h1>
+<p>You are unable to access example.com</p>
+<p>Cloudflare Ray ID: 8a1b2c3d4e5f6789</p></body></html>`,
+	}
+	blocked, likelihood := matcher.IsLikelyBlockPage(cloudflareBlock)
+	require.True(t, blocked)
+	require.InDelta(t, 0.7, likelihood, 0.001)
+
+	appError := Response{
+		StatusCode: 500,
+		Headers:    map[string]string{"Server": "gunicorn"},
+		Title:      "Server Error",
+		Body: `<html><body><nav><a href="/">Home</a></nav>
+<h1>Server Error (500)</h1><pre>Traceback (most recent call last):
+  File "app/views.py", line 42, in index</pre></body></html>`,
+	}
+	blocked, likelihood = matcher.IsLikelyBlockPage(appError)
+	require.False(t, blocked)
+	require.InDelta(t, 0.1, likelihood, 0.001)
+}
+
+func TestIsLikelyBlockPageThreshold(t *testing.T) {
+	heuristics := DefaultBlockPageHeuristics
+	heuristics.Threshold = 0.05
+	matcher, err := NewMatcher("", WithBlockPageHeuristics(heuristics))
+	require.NoError(t, err)
+
+	blocked, _ := matcher.IsLikelyBlockPage(Response{StatusCode: 500, Body: "<nav>traceback</nav>"})
+	require.True(t, blocked)
+}
@@ -0,0 +1,115 @@
+package cleanhttp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CacheSignature describes a header reporting the cache status of a CDN
+type CacheSignature struct {
+	// CDN is the name reported for the signature, empty if unknown
+	CDN string
+	// Header is the lowercased header carrying the cache status
+	Header string
+	// Contains, when set, must appear in the header value (case-insensitive)
+	Contains string
+	// Indicator, when set, is a lowercased header that must also be present
+	Indicator string
+	// Normalize converts the header value into a normalized status,
+	// defaulting to NormalizeCacheStatus
+	Normalize func(value string) string
+}
+
+// defaultCacheSignatures are checked in order by CacheInfo, the first
+// one present in the response wins
+var defaultCacheSignatures = []CacheSignature{
+	{CDN: "cloudflare", Header: "cf-cache-status"},
+	{CDN: "cloudfront", Header: "x-cache", Contains: "cloudfront"},
+	{CDN: "akamai", Header: "x-cache", Contains: "TCP_"},
+	{CDN: "fastly", Header: "x-cache", Indicator: "x-served-by"},
+	{CDN: "varnish", Header: "x-cache", Indicator: "x-varnish"},
+	{CDN: "varnish", Header: "x-varnish", Normalize: varnishCacheStatus},
+	{Header: "x-cache"},
+	{Header: "age", Normalize: ageCacheStatus},
+}
+
+// CacheInfo recognizes common cache status headers, and those added
+// WithCacheSignatures, returning the CDN they belong to (empty when the
+// header is generic) and the normalized cache status such as HIT, MISS
+// or EXPIRED
+func (m *Matcher) CacheInfo(resp Response) (cdn string, status string, ok bool) {
+	return cacheInfo(resp, m.cacheSignatures)
+}
+
+// cacheInfo returns the cache status of the first signature present in
+// the response
+func cacheInfo(resp Response, signatures []CacheSignature) (cdn string, status string, ok bool) {
+	headers := NormalizeHeaders(resp.Headers)
+	for _, signature := range signatures {
+		value, exists := headers[signature.Header]
+		if !exists {
+			continue
+		}
+		if signature.Contains != "" && !strings.Contains(strings.ToLower(value), strings.ToLower(signature.Contains)) {
+			continue
+		}
+		if signature.Indicator != "" {
+			if _, exists := headers[signature.Indicator]; !exists {
+				continue
+			}
+		}
+
+		normalize := signature.Normalize
+		if normalize == nil {
+			normalize = NormalizeCacheStatus
+		}
+		if status := normalize(value); status != "" {
+			return signature.CDN, status, true
+		}
+	}
+	return "", "", false
+}
+
+// NormalizeCacheStatus converts a cache status header value into one of
+// HIT, MISS, EXPIRED, BYPASS or DYNAMIC, or the uppercased value when it
+// is not recognized. For multi-hop values such as "MISS, HIT" the last
+// hop, closest to the client, is used.
+func NormalizeCacheStatus(value string) string {
+	if idx := strings.LastIndex(value, ","); idx >= 0 {
+		value = value[idx+1:]
+	}
+	value = strings.ToUpper(strings.TrimSpace(value))
+
+	switch {
+	case strings.Contains(value, "EXPIRED"), strings.Contains(value, "STALE"):
+		return "EXPIRED"
+	case strings.Contains(value, "HIT"):
+		return "HIT"
+	case strings.Contains(value, "MISS"):
+		return "MISS"
+	case strings.Contains(value, "PASS"):
+		return "BYPASS"
+	case strings.Contains(value, "DYNAMIC"):
+		return "DYNAMIC"
+	default:
+		return value
+	}
+}
+
+// varnishCacheStatus derives the status from X-Varnish, which holds the
+// request id followed by the id of the cached object on a hit
+func varnishCacheStatus(value string) string {
+	if len(strings.Fields(value)) > 1 {
+		return "HIT"
+	}
+	return "MISS"
+}
+
+// ageCacheStatus treats a positive Age as a cache hit
+func ageCacheStatus(value string) string {
+	age, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || age <= 0 {
+		return ""
+	}
+	return "HIT"
+}
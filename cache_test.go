@@ -0,0 +1,92 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheInfo(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantCDN    string
+		wantStatus string
+		wantOK     bool
+	}{
+		{
+			name:       "cloudflare",
+			headers:    map[string]string{"CF-Cache-Status": "EXPIRED", "Server": "cloudflare"},
+			wantCDN:    "cloudflare",
+			wantStatus: "EXPIRED",
+			wantOK:     true,
+		},
+		{
+			name:       "fastly shielded",
+			headers:    map[string]string{"X-Cache": "MISS, HIT", "X-Served-By": "cache-iad-kiad7000025-IAD, cache-ams21080-AMS"},
+			wantCDN:    "fastly",
+			wantStatus: "HIT",
+			wantOK:     true,
+		},
+		{
+			name:       "varnish x-cache",
+			headers:    map[string]string{"X-Cache": "miss", "X-Varnish": "32770"},
+			wantCDN:    "varnish",
+			wantStatus: "MISS",
+			wantOK:     true,
+		},
+		{
+			name:       "varnish ids only",
+			headers:    map[string]string{"X-Varnish": "32770 32771", "Via": "1.1 varnish (Varnish/7.1)"},
+			wantCDN:    "varnish",
+			wantStatus: "HIT",
+			wantOK:     true,
+		},
+		{
+			name:       "cloudfront",
+			headers:    map[string]string{"X-Cache": "RefreshHit from cloudfront"},
+			wantCDN:    "cloudfront",
+			wantStatus: "HIT",
+			wantOK:     true,
+		},
+		{
+			name:       "generic age",
+			headers:    map[string]string{"Age": "120"},
+			wantStatus: "HIT",
+			wantOK:     true,
+		},
+		{
+			name:    "no cache headers",
+			headers: map[string]string{"Server": "nginx", "Age": "0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cdn, status, ok := matcher.CacheInfo(Response{Headers: tt.headers})
+			require.Equal(t, tt.wantCDN, cdn)
+			require.Equal(t, tt.wantStatus, status)
+			require.Equal(t, tt.wantOK, ok)
+		})
+	}
+}
+
+func TestWithCacheSignatures(t *testing.T) {
+	matcher, err := NewMatcher("", WithCacheSignatures(CacheSignature{CDN: "bunnycdn", Header: "x-cache", Indicator: "cdn-pullzone"}))
+	require.NoError(t, err)
+
+	resp := Response{Headers: map[string]string{"X-Cache": "HIT", "CDN-PullZone": "12345"}}
+	cdn, status, ok := matcher.CacheInfo(resp)
+	require.True(t, ok)
+	require.Equal(t, "bunnycdn", cdn)
+	require.Equal(t, "HIT", status)
+
+	defaults, err := NewMatcher("")
+	require.NoError(t, err)
+	cdn, _, ok = defaults.CacheInfo(resp)
+	require.True(t, ok)
+	require.Empty(t, cdn)
+}
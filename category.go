@@ -0,0 +1,101 @@
+package cleanhttp
+
+import "slices"
+
+// Categories carried as tags by the default rules
+const (
+	CategoryWAF           = "waf"
+	CategoryCDN           = "cdn"
+	CategoryWebSocket     = "websocket"
+	CategoryRateLimit     = "rate-limit"
+	CategoryBotManagement = "bot-management"
+	CategoryEdgeCompute   = "edge-compute"
+	CategoryCAPTCHA       = "captcha"
+	CategoryPlaceholder   = "placeholder"
+	CategoryAPIGateway    = "api-gateway"
+)
+
+// optInCategories are the categories of rules that identify something
+// other than a WAF or CDN. Match skips rules tagged only with them
+// unless one of their tags is enabled with WithEnabledCategories.
+var optInCategories = []string{
+	CategoryRateLimit,
+	CategoryBotManagement,
+	CategoryEdgeCompute,
+	CategoryCAPTCHA,
+	CategoryPlaceholder,
+	CategoryAPIGateway,
+}
+
+// optInOnly reports whether the tags are all opt-in categories
+func optInOnly(tags []string) bool {
+	return len(tags) > 0 && !slices.ContainsFunc(tags, func(tag string) bool {
+		return !slices.Contains(optInCategories, tag)
+	})
+}
+
+// MatchCategory returns the sorted names of the providers whose rules
+// carry the category tag, such as CategoryBotManagement or
+// CategoryPlaceholder, and match the response. Only the rules of the
+// category are evaluated, including opt-in categories Match skips by
+// default. It returns nothing when categories are enabled, or matching
+// denies by default, and the category is not enabled.
+func (m *Matcher) MatchCategory(resp Response, category string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var names []string
+	for _, provider := range m.matchCategory(resp, category) {
+		if name := m.alias(provider); !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// matchCategory returns the rules carrying the category tag that match
+// the response, under their rule names. The caller must hold the read
+// lock.
+func (m *Matcher) matchCategory(resp Response, category string) []string {
+	if m.denyByDefault || len(m.enabledCategories) > 0 {
+		if _, ok := m.enabledCategories[category]; !ok {
+			return nil
+		}
+	}
+	return m.evalRules(m.newInput(resp, nil), func(provider string) bool {
+		return slices.Contains(m.rules[provider].Tags, category) && m.providerEnabled(provider)
+	}, 0)
+}
+
+// MatchBotManagement returns the names of the bot-management providers,
+// such as DataDome, PerimeterX or Kasada, that match the response
+func (m *Matcher) MatchBotManagement(resp Response) []string {
+	return m.MatchCategory(resp, CategoryBotManagement)
+}
+
+// MatchEdgeCompute returns the names of the serverless and edge-compute
+// platforms, such as Vercel, Netlify or Cloudflare Workers, that match
+// the response
+func (m *Matcher) MatchEdgeCompute(resp Response) []string {
+	return m.MatchCategory(resp, CategoryEdgeCompute)
+}
+
+// MatchCAPTCHA returns the names of the CAPTCHA vendors, such as
+// reCAPTCHA, hCaptcha or Cloudflare Turnstile, embedded in the response
+func (m *Matcher) MatchCAPTCHA(resp Response) []string {
+	return m.MatchCategory(resp, CategoryCAPTCHA)
+}
+
+// MatchPlaceholder returns the names of the placeholder pages, such as
+// default web server pages, parked domains or "coming soon" pages, that
+// match the response
+func (m *Matcher) MatchPlaceholder(resp Response) []string {
+	return m.MatchCategory(resp, CategoryPlaceholder)
+}
+
+// MatchAPIGateway returns the names of the API gateways, such as AWS API
+// Gateway, Kong, Tyk or Apigee, that match the response
+func (m *Matcher) MatchAPIGateway(resp Response) []string {
+	return m.MatchCategory(resp, CategoryAPIGateway)
+}
@@ -0,0 +1,262 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPlaceholder(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		title string
+		body  string
+		want  []string
+	}{
+		{
+			name:  "nginx welcome",
+			title: "Welcome to nginx!",
+			body:  "<h1>Welcome to nginx!</h1><p>If you see this page, the nginx web server is successfully installed and working.</p>",
+			want:  []string{"nginx_default"},
+		},
+		{
+			name: "apache it works",
+			body: "<html><body><h1>It works!</h1></body></html>",
+			want: []string{"apache_default"},
+		},
+		{
+			name:  "apache ubuntu default",
+			title: "Apache2 Ubuntu Default Page: It works",
+			want:  []string{"apache_default"},
+		},
+		{
+			name:  "iis",
+			title: "IIS Windows Server",
+			want:  []string{"iis_default"},
+		},
+		{
+			name: "parked domain",
+			body: `<script src="//www.sedoparking.com/frmpark/example.com/park.js"></script>`,
+			want: []string{"parked_domain"},
+		},
+		{
+			name:  "coming soon",
+			title: "Coming Soon - Example Store",
+			want:  []string{"coming_soon"},
+		},
+		{
+			name:  "real application",
+			title: "Dashboard",
+			body:  "<h1>It works well for teams</h1>",
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, matcher.MatchPlaceholder(Response{StatusCode: 200, Title: tt.title, Body: tt.body}))
+		})
+	}
+}
+
+func TestMatchCAPTCHA(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "hcaptcha",
+			body: `<script src="https://js.hcaptcha.com/1/api.js" async defer></script><div class="h-captcha" data-sitekey="10000000-ffff-ffff-ffff-000000000001"></div>`,
+			want: []string{"hcaptcha"},
+		},
+		{
+			name: "turnstile",
+			body: `<script src="https://challenges.cloudflare.com/turnstile/v0/api.js" defer></script>`,
+			want: []string{"turnstile"},
+		},
+		{
+			name: "recaptcha",
+			body: `<script src="https://www.google.com/recaptcha/api.js"></script><div class="g-recaptcha"></div>`,
+			want: []string{"recaptcha"},
+		},
+		{
+			name: "no captcha",
+			body: `<html><body>Welcome</body></html>`,
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, matcher.MatchCAPTCHA(Response{StatusCode: 200, Body: tt.body}))
+		})
+	}
+}
+
+func TestMatchEdgeCompute(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    []string
+	}{
+		{
+			name:    "vercel",
+			headers: map[string]string{"Server": "Vercel", "X-Vercel-Id": "iad1::iad1::8k2xq-1700000000000-abcdef123456", "X-Vercel-Cache": "MISS"},
+			want:    []string{"vercel"},
+		},
+		{
+			name:    "netlify",
+			headers: map[string]string{"Server": "Netlify", "X-Nf-Request-Id": "01HF8Z3Q7J9K2M4N6P8R0T2V4X"},
+			want:    []string{"netlify"},
+		},
+		{
+			name:    "cloudflare workers",
+			headers: map[string]string{"Cf-Worker": "example.workers.dev"},
+			want:    []string{"cloudflare_workers"},
+		},
+		{
+			name:    "plain origin",
+			headers: map[string]string{"Server": "nginx"},
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, matcher.MatchEdgeCompute(Response{StatusCode: 200, Headers: tt.headers}))
+		})
+	}
+}
+
+func TestMatchBotManagement(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    []string
+	}{
+		{
+			name:    "datadome cookie",
+			headers: map[string]string{"Set-Cookie": "datadome=AHrlqAAAAAMA; Max-Age=31536000; Domain=.example.com; Path=/; Secure; SameSite=Lax"},
+			want:    []string{"datadome"},
+		},
+		{
+			name:    "datadome header",
+			headers: map[string]string{"X-DataDome": "protected", "Server": "nginx"},
+			want:    []string{"datadome"},
+		},
+		{
+			name:    "perimeterx cookies joined",
+			headers: map[string]string{"Set-Cookie": "session=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT, _pxhd=abc123:def; path=/"},
+			want:    []string{"perimeterx"},
+		},
+		{
+			name:    "kasada",
+			headers: map[string]string{"x-kpsdk-ct": "0123"},
+			want:    []string{"kasada"},
+		},
+		{
+			name:    "unrelated cookie",
+			headers: map[string]string{"Set-Cookie": "pxsession=1; Path=/"},
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, matcher.MatchBotManagement(Response{StatusCode: 200, Headers: tt.headers}))
+		})
+	}
+}
+
+func TestMatchAPIGateway(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		response Response
+		want     []string
+	}{
+		{
+			name: "aws missing token",
+			response: Response{
+				StatusCode: 403,
+				Headers: map[string]string{
+					"Content-Type":     "application/json",
+					"X-Amzn-RequestId": "6f5c1c1e-8d2a-4b3f-9a51-0c2b7e6d4a10",
+					"X-Amzn-ErrorType": "MissingAuthenticationTokenException",
+				},
+				Body: `{"message":"Missing Authentication Token"}`,
+			},
+			want: []string{"aws_api_gateway"},
+		},
+		{
+			name: "aws apigw id",
+			response: Response{
+				StatusCode: 200,
+				Headers:    map[string]string{"x-amzn-requestid": "6f5c1c1e", "x-amz-apigw-id": "Jk2abcDEF="},
+				Body:       `{"items": []}`,
+			},
+			want: []string{"aws_api_gateway"},
+		},
+		{
+			name: "aws request id alone",
+			response: Response{
+				StatusCode: 200,
+				Headers:    map[string]string{"X-Amzn-RequestId": "6f5c1c1e"},
+				Body:       `{"items": []}`,
+			},
+			want: nil,
+		},
+		{
+			name: "kong no route",
+			response: Response{
+				StatusCode: 404,
+				Headers: map[string]string{
+					"Content-Type":            "application/json; charset=utf-8",
+					"Server":                  "kong/3.4.2",
+					"X-Kong-Response-Latency": "0",
+				},
+				Body: `{"message":"no Route matched with those values"}`,
+			},
+			want: []string{"kong"},
+		},
+		{
+			name: "apigee fault",
+			response: Response{
+				StatusCode: 401,
+				Body:       `{"fault":{"faultstring":"Invalid ApiKey","detail":{"errorcode":"oauth.v2.InvalidApiKey"}}}`,
+			},
+			want: []string{"apigee"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, matcher.MatchAPIGateway(tt.response))
+		})
+	}
+}
+
+func TestMatchCategoryOptIn(t *testing.T) {
+	resp := Response{StatusCode: 200, Headers: map[string]string{"X-DataDome": "protected", "X-Vercel-Id": "iad1::abc"}}
+
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.Empty(t, matcher.Match(resp), "opt-in categories are skipped by default")
+	require.Equal(t, []string{"datadome"}, matcher.MatchCategory(resp, CategoryBotManagement))
+	require.Empty(t, matcher.MatchCategory(resp, CategoryWAF))
+
+	matcher, err = NewMatcher("", WithEnabledCategories(CategoryEdgeCompute))
+	require.NoError(t, err)
+	require.Equal(t, []string{"vercel"}, matcher.Match(resp))
+	require.Empty(t, matcher.MatchCategory(resp, CategoryBotManagement), "category is not enabled")
+}
@@ -0,0 +1,42 @@
+package cleanhttp
+
+import (
+	"mime"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+var metaCharsetRegex = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?([\w.:-]+)`)
+
+// bodyCharset returns the lowercased charset declared by the
+// Content-Type header or, failing that, by a meta tag in the body
+func bodyCharset(contentType, body string) string {
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		return strings.ToLower(params["charset"])
+	}
+	head := body[:min(len(body), 1024)]
+	if matches := metaCharsetRegex.FindStringSubmatch(head); len(matches) == 2 {
+		return strings.ToLower(matches[1])
+	}
+	return ""
+}
+
+// decodeCharset transcodes the strings from the charset to UTF-8. They
+// are returned unchanged when the charset is UTF-8, unknown or invalid.
+func decodeCharset(charset string, values ...*string) {
+	if charset == "" || charset == "utf-8" || charset == "utf8" {
+		return
+	}
+	encoding, err := htmlindex.Get(charset)
+	if err != nil {
+		return
+	}
+	for _, value := range values {
+		decoded, err := encoding.NewDecoder().String(*value)
+		if err == nil {
+			*value = decoded
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestWithCharsetDecode(t *testing.T) {
+	body, err := japanese.ShiftJIS.NewEncoder().String("<html><body>アクセスが拒否されました</body></html>")
+	require.NoError(t, err)
+	rules := []byte(`{"services": {"jp_waf": {"http_body": ["アクセスが拒否されました"]}}}`)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		body    string
+	}{
+		{name: "content type", headers: map[string]string{"Content-Type": "text/html; charset=Shift_JIS"}, body: body},
+		{name: "meta tag", headers: map[string]string{"Content-Type": "text/html"}, body: `<meta charset="shift_jis">` + body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := Response{StatusCode: 403, Headers: tt.headers, Body: tt.body}
+
+			plain, err := newMatcher(rules, nil)
+			require.NoError(t, err)
+			require.Empty(t, plain.Match(resp))
+
+			decoding, err := newMatcher(rules, []Option{WithCharsetDecode(true)})
+			require.NoError(t, err)
+			require.Equal(t, []string{"jp_waf"}, decoding.Match(resp))
+		})
+	}
+}
+
+func TestDecodeCharsetUnknown(t *testing.T) {
+	value := "caf\xe9"
+	decodeCharset("x-unknown", &value)
+	require.Equal(t, "caf\xe9", value)
+
+	decodeCharset("iso-8859-1", &value)
+	require.Equal(t, "café", value)
+}
@@ -0,0 +1,138 @@
+package cleanhttp
+
+import (
+	"slices"
+	"strings"
+)
+
+// DefaultPriorities ranks known vendors when several match a response.
+// Variant rules (e.g. cloudflare_redirection) are attributed to the
+// vendor named by their parent. Matchers copy it when created, so
+// changing it only affects matchers created afterwards.
+var DefaultPriorities = map[string]int{
+	"cloudflare": 100,
+	"akamai":     90,
+	"cloudfront": 80,
+}
+
+// Verdict is the single classification of a set of matches
+type Verdict struct {
+	Host     string   `json:"host,omitempty"`
+	Provider string   `json:"provider"`
+	Roles    []string `json:"roles,omitempty"`
+	Matches  []string `json:"matches,omitempty"`
+}
+
+// Classify collapses matched provider names into a single verdict for
+// the highest priority vendor, with its roles taken from the tags of
+// the matching rules
+func (m *Matcher) Classify(matches []string) Verdict {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var verdict Verdict
+	bestPriority := 0
+	for _, match := range matches {
+		vendor := m.vendor(match)
+		priority := m.priorities[vendor]
+		if verdict.Provider == "" || priority > bestPriority || (priority == bestPriority && vendor < verdict.Provider) {
+			verdict.Provider = vendor
+			bestPriority = priority
+		}
+	}
+	if verdict.Provider == "" {
+		return verdict
+	}
+
+	for _, match := range matches {
+		if m.vendor(match) != verdict.Provider {
+			continue
+		}
+		verdict.Matches = append(verdict.Matches, match)
+		for _, tag := range m.tags(match) {
+			if !slices.Contains(verdict.Roles, tag) {
+				verdict.Roles = append(verdict.Roles, tag)
+			}
+		}
+	}
+	slices.Sort(verdict.Matches)
+	slices.Sort(verdict.Roles)
+	return verdict
+}
+
+// HostVerdict matches every response collected for a host, e.g. over
+// several ports or paths, and classifies the union of their matches
+// into a single verdict for the host
+func (m *Matcher) HostVerdict(host string, responses []Response) Verdict {
+	var matches []string
+	for _, resp := range responses {
+		for _, provider := range m.Match(resp) {
+			if !slices.Contains(matches, provider) {
+				matches = append(matches, provider)
+			}
+		}
+	}
+	verdict := m.Classify(matches)
+	verdict.Host = host
+	return verdict
+}
+
+// Tags returns the tags of the provider's rule, which Classify reports
+// as its roles, or nil for unknown providers
+func (m *Matcher) Tags(provider string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Clone(m.rules[provider].Tags)
+}
+
+// tags returns the tags of the rule of a matched name, or of the rules
+// aliased to it. The caller must hold the read lock.
+func (m *Matcher) tags(name string) []string {
+	if rule, ok := m.rules[name]; ok || len(m.aliases) == 0 {
+		return rule.Tags
+	}
+	var tags []string
+	for provider, canonical := range m.aliases {
+		if canonical == name {
+			tags = append(tags, m.rules[provider].Tags...)
+		}
+	}
+	return tags
+}
+
+// sortRules orders the rule names by decreasing priority of their
+// vendor, then by name, for evalRules. The caller must hold the write
+// lock.
+func (m *Matcher) sortRules() {
+	providers := make([]string, 0, len(m.rules))
+	for provider := range m.rules {
+		providers = append(providers, provider)
+	}
+	slices.SortFunc(providers, func(a, b string) int {
+		if pa, pb := m.priorities[m.vendor(a)], m.priorities[m.vendor(b)]; pa != pb {
+			return pb - pa
+		}
+		return strings.Compare(a, b)
+	})
+	m.order = providers
+}
+
+// vendor returns the vendor a rule or matched name belongs to: the
+// aliased parent of a variant rule, or the aliased name itself. The
+// caller must hold the read lock.
+func (m *Matcher) vendor(name string) string {
+	rule, ok := m.rules[name]
+	if !ok {
+		for provider, canonical := range m.aliases {
+			if canonical == name && m.rules[provider].Parent != "" {
+				rule = m.rules[provider]
+				break
+			}
+		}
+	}
+	if rule.Parent != "" {
+		return m.alias(rule.Parent)
+	}
+	return m.alias(name)
+}
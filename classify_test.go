@@ -0,0 +1,80 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	verdict := matcher.Classify([]string{"cloudflare_redirection", "cloudflare"})
+	require.Equal(t, Verdict{
+		Provider: "cloudflare",
+		Roles:    []string{"cdn", "waf"},
+		Matches:  []string{"cloudflare", "cloudflare_redirection"},
+	}, verdict)
+
+	verdict = matcher.Classify([]string{"cloudfront", "akamai"})
+	require.Equal(t, "akamai", verdict.Provider)
+
+	require.Equal(t, Verdict{}, matcher.Classify(nil))
+}
+
+func TestTags(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"bot-management"}, matcher.Tags("datadome"))
+	require.Nil(t, matcher.Tags("missing"))
+}
+
+func TestClassifyPriorities(t *testing.T) {
+	matcher, err := NewMatcher("", WithPriorities(map[string]int{"cloudfront": 10, "akamai": 1}))
+	require.NoError(t, err)
+
+	verdict := matcher.Classify([]string{"cloudfront", "akamai"})
+	require.Equal(t, "cloudfront", verdict.Provider)
+	require.Equal(t, []string{"cdn"}, verdict.Roles)
+}
+
+func TestHostVerdict(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("cloudflare_not_found", RuleJSON{
+		Parent:         "cloudflare",
+		HTTPStatusCode: "404",
+		HTTPHeader:     map[string]HeaderPatterns{"Server": {"cloudflare"}},
+		Tags:           []string{"cdn"},
+	}))
+	require.NoError(t, matcher.AddRule("cloudflare_challenge", RuleJSON{
+		Parent:         "cloudflare",
+		HTTPStatusCode: "403",
+		HTTPTitle:      "Just a moment...",
+		Tags:           []string{"waf"},
+	}))
+
+	root := Response{
+		StatusCode: 403,
+		Headers:    map[string]string{"Server": "cloudflare", "CF-RAY": "8a1b2c3d4e5f6a7b-AMS"},
+		Title:      "Just a moment...",
+	}
+	notFound := Response{
+		StatusCode: 404,
+		Headers:    map[string]string{"Server": "cloudflare"},
+		Body:       "<h1>Not Found</h1>",
+	}
+	unmatched := Response{StatusCode: 200, Title: "Home"}
+
+	verdict := matcher.HostVerdict("example.com", []Response{root, notFound, unmatched})
+	require.Equal(t, Verdict{
+		Host:     "example.com",
+		Provider: "cloudflare",
+		Roles:    []string{"cdn", "waf"},
+		Matches:  []string{"cloudflare_challenge", "cloudflare_not_found"},
+	}, verdict)
+
+	require.Equal(t, Verdict{Host: "example.com"}, matcher.HostVerdict("example.com", []Response{unmatched}))
+}
@@ -6,65 +6,244 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
-	"net/url"
+	"maps"
+	"mime"
 	"os"
 	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 //go:embed rules.json
 var defaultRules []byte
 
-// Response contains the HTTP response data to match against
-type Response struct {
-	StatusCode int
-	Headers    map[string]string
-	Body       string
-	Title      string
-	RequestURL string
+// DefaultRules returns a copy of the embedded default rules JSON
+func DefaultRules() []byte {
+	return slices.Clone(defaultRules)
 }
 
-// CheckRedirect represents redirect checking configuration
-type CheckRedirect struct {
-	SourcePorts []int `json:"source_ports"`
-	TargetPorts []int `json:"target_ports"`
+// Response contains the HTTP response data to match against
+type Response struct {
+	StatusCode     int
+	StatusReason   string
+	Headers        map[string]string
+	HeaderPairs    [][2]string
+	Body           string
+	Title          string
+	Titles         []string
+	RequestURL     string
+	Method         string
+	RequestHeaders map[string]string
+	RedirectChain  []string
+	Trailers       map[string]string
+	RawHeaders     string
+	// ALPN is the negotiated application protocol (e.g. h2, http/1.1)
+	ALPN string
+	// H2Settings are the HTTP/2 SETTINGS values sent by the server
+	H2Settings    map[string]uint32
+	ConnReset     bool
+	GoAway        bool
+	FailureReason string
+	TLSSANs       []string
+	TLSCommonName string
+	ASN           int
+	ASNOrg        string
 }
 
 // RuleJSON represents the JSON structure for loading rules
 type RuleJSON struct {
-	HTTPStatusCode string            `json:"http_status_code,omitempty"`
-	HTTPHeader     map[string]string `json:"http_header,omitempty"`
-	HTTPBody       []string          `json:"http_body,omitempty"`
-	HTTPBodyRegex  []string          `json:"http_body_regex,omitempty"`
-	HTTPTitle      string            `json:"http_title,omitempty"`
-	CheckRedirect  *CheckRedirect    `json:"check_redirect,omitempty"`
+	// Ref names the definitions a service inherits from. It is expanded
+	// and cleared when the rules document is loaded.
+	Ref                 json.RawMessage           `json:"$ref,omitempty"`
+	ID                  string                    `json:"id,omitempty"`
+	Parent              string                    `json:"parent,omitempty"`
+	HTTPStatusCode      string                    `json:"http_status_code,omitempty"`
+	HTTPStatusClass     string                    `json:"http_status_class,omitempty"`
+	HTTPStatusReason    string                    `json:"http_status_reason_contains,omitempty"`
+	HTTPMethod          []string                  `json:"http_method,omitempty"`
+	HTTPHeader          map[string]HeaderPatterns `json:"http_header,omitempty"`
+	HTTPTrailer         map[string]string         `json:"http_trailer,omitempty"`
+	HTTPHeaderPresent   []string                  `json:"http_header_present,omitempty"`
+	HTTPHeaderCount     map[string]int            `json:"http_header_count,omitempty"`
+	HTTPAgeMin          int                       `json:"http_age_min,omitempty"`
+	HTTPAgeMax          int                       `json:"http_age_max,omitempty"`
+	HTTPVary            []string                  `json:"http_vary,omitempty"`
+	HTTPCookie          []string                  `json:"http_cookie,omitempty"`
+	HTTPLinkRel         string                    `json:"http_link_rel,omitempty"`
+	TechStack           []string                  `json:"tech_stack,omitempty"`
+	HTTPLinkContains    []string                  `json:"http_link_contains,omitempty"`
+	HTTPReflectedHeader []string                  `json:"http_reflected_header,omitempty"`
+	MinSecurityHeaders  int                       `json:"min_security_headers,omitempty"`
+	HTTPContentType     []string                  `json:"http_content_type,omitempty"`
+	HTTPHeadersRegex    []RegexPattern            `json:"http_headers_regex,omitempty"`
+	HTTPBody            []string                  `json:"http_body,omitempty"`
+	HTTPBodyLine        []string                  `json:"http_body_line_contains,omitempty"`
+	HTTPBodyLengthMin   int                       `json:"http_body_length_min,omitempty"`
+	HTTPBodyLengthMax   int                       `json:"http_body_length_max,omitempty"`
+	HTTPBodyRegex       []RegexPattern            `json:"http_body_regex,omitempty"`
+	HTTPJSON            map[string]string         `json:"http_json,omitempty"`
+	RequireBody         bool                      `json:"require_body,omitempty"`
+	HTTPTitle           string                    `json:"http_title,omitempty"`
+	HTTPTitleRegex      *RegexPattern             `json:"http_title_regex,omitempty"`
+	HTTPTitleFuzzy      *FuzzyTitle               `json:"http_title_fuzzy,omitempty"`
+	CheckRedirect       *CheckRedirect            `json:"check_redirect,omitempty"`
+	MatchGroups         []RuleJSON                `json:"match_groups,omitempty"`
+	On404               *RuleJSON                 `json:"on_404,omitempty"`
+	ValidFrom           string                    `json:"valid_from,omitempty"`
+	ValidUntil          string                    `json:"valid_until,omitempty"`
+	CaseInsensitive     bool                      `json:"case_insensitive,omitempty"`
+	Tags                []string                  `json:"tags,omitempty"`
+	ALPN                string                    `json:"alpn,omitempty"`
+	H2Settings          map[string]uint32         `json:"h2_settings,omitempty"`
+	ConnReset           bool                      `json:"conn_reset,omitempty"`
+	GoAway              bool                      `json:"goaway,omitempty"`
+	HTTPFailureContains []string                  `json:"http_failure_contains,omitempty"`
+	TLSSANContains      []string                  `json:"tls_san_contains,omitempty"`
+	TLSCNContains       string                    `json:"tls_cn_contains,omitempty"`
+	ASN                 []int                     `json:"asn,omitempty"`
+	OrgContains         string                    `json:"org_contains,omitempty"`
+	Weights             map[string]float64        `json:"weights,omitempty"`
+	MinScore            float64                   `json:"min_score,omitempty"`
 }
 
+// SchemaVersion is the newest version of the rules format this package
+// understands. It is raised when keys are added to the format.
+const SchemaVersion = 1
+
 // ServicesJSON represents the root JSON structure
 type ServicesJSON struct {
+	// Version is the schema version the rules were written for, zero
+	// when unspecified
+	Version  int                 `json:"version,omitempty"`
 	Services map[string]RuleJSON `json:"services"`
 }
 
+// checkSchemaVersion returns an error when the rules were written for a
+// newer schema than this package supports
+func checkSchemaVersion(version int) error {
+	if version > SchemaVersion {
+		return fmt.Errorf("rules schema version %d is newer than the supported version %d, upgrade cleanhttp to load them", version, SchemaVersion)
+	}
+	return nil
+}
+
 // Rule contains the compiled patterns for matching
 type Rule struct {
-	StatusMin     int
-	StatusMax     int
-	Headers       map[string]string
-	BodyContains  []string
-	BodyRegex     []*regexp.Regexp
-	TitleExact    string
-	RedirectCheck *CheckRedirect
+	ID     string
+	Parent string
+	// HasStatus reports whether the rule has a status condition, so that
+	// http_status_code "0" requires a response without a status code
+	// instead of leaving the status unchecked
+	HasStatus          bool
+	StatusMin          int
+	StatusMax          int
+	StatusReason       string
+	Methods            []string
+	Headers            map[string][]string
+	Trailers           map[string][]string
+	HeadersPresent     []string
+	HeaderCounts       map[string]int
+	AgeMin             int
+	AgeMax             int
+	Vary               []string
+	Cookies            []string
+	LinkRel            string
+	TechStack          []string
+	LinkContains       []string
+	ReflectedHeaders   []string
+	MinSecurityHeaders int
+	ContentTypes       []string
+	RawHeaderRegex     []*regexp.Regexp
+	BodyContains       []string
+	BodyLines          []string
+	BodyLengthMin      int
+	BodyLengthMax      int
+	BodyRegex          []*regexp.Regexp
+	JSONFields         map[string]string
+	RequireBody        bool
+	TitleExact         string
+	TitleRegex         *regexp.Regexp
+	TitleFuzzy         *FuzzyTitle
+	RedirectCheck      *CheckRedirect
+	Groups             []Rule
+	On404              *Rule
+	ValidFrom          time.Time
+	ValidUntil         time.Time
+	CaseInsensitive    bool
+	Tags               []string
+	ALPN               string
+	H2Settings         map[string]uint32
+	ConnReset          bool
+	GoAway             bool
+	FailureContains    []string
+	TLSSANs            []string
+	TLSCommonName      string
+	ASN                []int
+	OrgContains        string
+	Weights            map[string]float64
+	MinScore           float64
+
+	// needsFullBody is set when a body condition can be invalidated by
+	// bytes past a body prefix: end-sensitive regexes and JSON fields.
+	// Body length bounds are safe, as they only use the Content-Length
+	// header when the body was not fully read.
+	needsFullBody bool
 }
 
 // Matcher handles the WAF/CDN detection rules
 type Matcher struct {
-	rules map[string]Rule
+	mu     sync.RWMutex
+	rules  map[string]Rule
+	order  []string // rule names by decreasing vendor priority, see sortRules
+	custom []CustomMatcher
+
+	maxRegexInput int
+	matchBudget   time.Duration
+	priorities    map[string]int
+	profiling     bool
+	strict        bool
+	titleOnly     bool
+	collapse      bool
+
+	sanitizeControl bool
+	charsetDecode   bool
+	extractTitle    func(body string) string
+	clock           func() time.Time
+
+	blockPageHeuristics BlockPageHeuristics
+
+	// Tables copied from the defaults and extended by options
+	techHeaders     []TechHeader
+	serverParsers   []ServerParser
+	leakPatterns    []LeakPattern
+	cacheSignatures []CacheSignature
+	providerAliases map[string]string
+
+	enabledProviders  map[string]struct{}
+	disabledProviders map[string]struct{}
+	// enabledCategories restricts matching to rules with one of the
+	// tags, denyByDefault matches nothing while it is empty
+	enabledCategories map[string]struct{}
+	denyByDefault     bool
+	// aliases maps rule names to the names reported in results
+	aliases map[string]string
+
+	defaultStatusMin int
+	defaultStatusMax int
+
+	// caseInsensitiveRules counts rules needing a lowercased response
+	caseInsensitiveRules int
+
+	statsMu sync.Mutex
+	stats   map[string]*RuleStats
 }
 
-// NewMatcher creates a Matcher instance with compiled rules from JSON
-func NewMatcher(rulesPath string) (*Matcher, error) {
+// NewMatcher creates a Matcher instance with compiled rules from JSON.
+// Rule files with a .yaml or .yml extension are parsed as YAML.
+func NewMatcher(rulesPath string, opts ...Option) (*Matcher, error) {
 	var data []byte
 	var err error
 
@@ -75,51 +254,297 @@ func NewMatcher(rulesPath string) (*Matcher, error) {
 		if err != nil {
 			return nil, fmt.Errorf("reading rules file: %w", err)
 		}
+		if isYAMLPath(rulesPath) {
+			return newMatcherFromYAML(data, opts)
+		}
 	}
 
-	var servicesJSON ServicesJSON
-	if err := json.Unmarshal(data, &servicesJSON); err != nil {
-		return nil, fmt.Errorf("parsing rules JSON: %w", err)
+	return newMatcher(data, opts)
+}
+
+// defaultMatcher compiles the embedded rules on first use
+var defaultMatcher = sync.OnceValue(func() *Matcher {
+	m, err := newMatcher(defaultRules, nil)
+	if err != nil {
+		panic(fmt.Sprintf("cleanhttp: compiling default rules: %v", err))
 	}
+	return m
+})
 
-	rules := make(map[string]Rule)
-	for provider, jsonRule := range servicesJSON.Services {
-		rule, err := compileRule(jsonRule)
-		if err != nil {
-			return nil, fmt.Errorf("compiling rule for %s: %w", provider, err)
-		}
-		rules[provider] = rule
+// DefaultMatcher returns a package-level matcher built from the
+// embedded rules with default options. It is compiled on first use and
+// shared by every caller, so it is safe for concurrent matching but
+// must not be modified with AddRule or RegisterCustom; create a
+// dedicated matcher with NewMatcher instead.
+func DefaultMatcher() *Matcher {
+	return defaultMatcher()
+}
+
+// newMatcher creates a Matcher with the given options and compiles the
+// JSON rules into it
+func newMatcher(data []byte, opts []Option) (*Matcher, error) {
+	m := emptyMatcher(opts)
+	if err := m.AddRules(data); err != nil {
+		return nil, err
 	}
+	return m, nil
+}
+
+// emptyMatcher creates a Matcher without rules with the given options
+func emptyMatcher(opts []Option) *Matcher {
+	m := &Matcher{
+		rules:      make(map[string]Rule),
+		stats:      make(map[string]*RuleStats),
+		priorities: maps.Clone(DefaultPriorities),
 
-	return &Matcher{rules: rules}, nil
+		extractTitle:        ExtractTitle,
+		clock:               time.Now,
+		blockPageHeuristics: DefaultBlockPageHeuristics,
+
+		techHeaders:     slices.Clone(defaultTechHeaders),
+		leakPatterns:    slices.Clone(defaultLeakPatterns),
+		cacheSignatures: slices.Clone(defaultCacheSignatures),
+		providerAliases: maps.Clone(defaultProviderAliases),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
+// AddRules compiles the services in the JSON data and adds them to the
+// matcher, replacing existing rules for the same providers
 func (m *Matcher) AddRules(data []byte) error {
-	var servicesJSON ServicesJSON
-	if err := json.Unmarshal(data, &servicesJSON); err != nil {
-		return fmt.Errorf("parsing rules JSON: %w", err)
+	servicesJSON, err := decodeRules(data, m.strict)
+	if err != nil {
+		return err
 	}
+	if err := checkSchemaVersion(servicesJSON.Version); err != nil {
+		return err
+	}
+	return m.addServices(servicesJSON.Services)
+}
 
-	for provider, jsonRule := range servicesJSON.Services {
+// addServices compiles the rules and adds them to the matcher
+func (m *Matcher) addServices(services map[string]RuleJSON) error {
+	compiled := make(map[string]Rule, len(services))
+	for provider, jsonRule := range services {
 		ruleCompiled, err := compileRule(jsonRule)
 		if err != nil {
-			return fmt.Errorf("compiling rule for %s: %w", provider, err)
+			return &RuleError{Provider: provider, Err: err}
 		}
-		m.rules[provider] = ruleCompiled
+		compiled[provider] = ruleCompiled
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for provider, rule := range compiled {
+		m.setRule(provider, rule)
 	}
+	m.sortRules()
 	return nil
 }
 
+// AddRule compiles a single rule and adds it to the matcher, replacing
+// any existing rule for the provider. Compile failures are returned as
+// a *RuleError.
+func (m *Matcher) AddRule(provider string, rule RuleJSON) error {
+	compiled, err := compileRule(rule)
+	if err != nil {
+		return &RuleError{Provider: provider, Err: err}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.setRule(provider, compiled)
+	m.sortRules()
+	return nil
+}
+
+// setRule stores a compiled rule, applying matcher level defaults.
+// Callers must call sortRules once done. The caller must hold the write
+// lock.
+func (m *Matcher) setRule(provider string, rule Rule) {
+	if rule.ID == "" {
+		rule.ID = provider
+	}
+	if !rule.HasStatus && (m.defaultStatusMin != 0 || m.defaultStatusMax != 0) {
+		rule.HasStatus = true
+		rule.StatusMin = m.defaultStatusMin
+		rule.StatusMax = m.defaultStatusMax
+	}
+	if existing, ok := m.rules[provider]; ok && existing.usesCaseInsensitive() {
+		m.caseInsensitiveRules--
+	}
+	if rule.usesCaseInsensitive() {
+		m.caseInsensitiveRules++
+	}
+	m.rules[provider] = rule
+}
+
+// RuleError is returned when a provider rule fails to compile
+type RuleError struct {
+	Provider string
+	Err      error
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("compiling rule for %s: %v", e.Provider, e.Err)
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
 // compileRule converts a JSON rule into a compiled Rule
 func compileRule(jr RuleJSON) (Rule, error) {
+	if len(jr.Ref) > 0 {
+		return Rule{}, fmt.Errorf("$ref is only supported at the top level of services and definitions")
+	}
 	rule := Rule{
-		Headers:       make(map[string]string),
-		BodyContains:  jr.HTTPBody,
-		TitleExact:    jr.HTTPTitle,
-		RedirectCheck: jr.CheckRedirect,
+		ID:                 jr.ID,
+		Parent:             jr.Parent,
+		StatusReason:       strings.ToLower(jr.HTTPStatusReason),
+		MinSecurityHeaders: jr.MinSecurityHeaders,
+		AgeMin:             jr.HTTPAgeMin,
+		AgeMax:             jr.HTTPAgeMax,
+		JSONFields:         jr.HTTPJSON,
+		RequireBody:        jr.RequireBody,
+		BodyLengthMin:      jr.HTTPBodyLengthMin,
+		BodyLengthMax:      jr.HTTPBodyLengthMax,
+		Headers:            make(map[string][]string, len(jr.HTTPHeader)),
+		Trailers:           headerValues(NormalizeHeaders(jr.HTTPTrailer)),
+		BodyContains:       jr.HTTPBody,
+		TitleExact:         jr.HTTPTitle,
+		TitleFuzzy:         jr.HTTPTitleFuzzy,
+		RedirectCheck:      jr.CheckRedirect,
+		Tags:               jr.Tags,
+		ALPN:               jr.ALPN,
+		H2Settings:         jr.H2Settings,
+		ConnReset:          jr.ConnReset,
+		GoAway:             jr.GoAway,
+		TLSCommonName:      strings.ToLower(jr.TLSCNContains),
+		ASN:                jr.ASN,
+		OrgContains:        strings.ToLower(jr.OrgContains),
+		Weights:            jr.Weights,
+		MinScore:           jr.MinScore,
+	}
+
+	if err := validateWeights(jr.Weights, jr.MinScore); err != nil {
+		return Rule{}, err
+	}
+	for path := range jr.HTTPJSON {
+		if err := validateJSONPath(path); err != nil {
+			return Rule{}, err
+		}
+	}
+	if jr.HTTPAgeMin < 0 || jr.HTTPAgeMax < 0 {
+		return Rule{}, fmt.Errorf("age bounds cannot be negative")
+	}
+	if jr.HTTPAgeMax > 0 && jr.HTTPAgeMin > jr.HTTPAgeMax {
+		return Rule{}, fmt.Errorf("http_age_min %d exceeds http_age_max %d", jr.HTTPAgeMin, jr.HTTPAgeMax)
+	}
+	if jr.HTTPBodyLengthMin < 0 || jr.HTTPBodyLengthMax < 0 {
+		return Rule{}, fmt.Errorf("body length bounds cannot be negative")
+	}
+	if jr.HTTPBodyLengthMax > 0 && jr.HTTPBodyLengthMin > jr.HTTPBodyLengthMax {
+		return Rule{}, fmt.Errorf("http_body_length_min %d exceeds http_body_length_max %d", jr.HTTPBodyLengthMin, jr.HTTPBodyLengthMax)
+	}
+	if jr.CheckRedirect != nil {
+		for _, status := range jr.CheckRedirect.StatusCodes {
+			if status < 300 || status > 399 {
+				return Rule{}, fmt.Errorf("invalid redirect status code %d", status)
+			}
+		}
+	}
+
+	for header, patterns := range jr.HTTPHeader {
+		if len(patterns) == 0 {
+			return Rule{}, fmt.Errorf("header %s has no patterns", header)
+		}
+		key := strings.ToLower(header)
+		rule.Headers[key] = append(rule.Headers[key], patterns...)
+	}
+
+	// Case-insensitive rules are compared against a lowercased response
+	if jr.CaseInsensitive {
+		rule.CaseInsensitive = true
+		for header, patterns := range rule.Headers {
+			lowered := make([]string, len(patterns))
+			for i, pattern := range patterns {
+				lowered[i] = strings.ToLower(pattern)
+			}
+			rule.Headers[header] = lowered
+		}
+		rule.BodyContains = make([]string, len(jr.HTTPBody))
+		for i, pattern := range jr.HTTPBody {
+			rule.BodyContains[i] = strings.ToLower(pattern)
+		}
+		rule.TitleExact = strings.ToLower(jr.HTTPTitle)
+		if jr.HTTPTitleFuzzy != nil {
+			rule.TitleFuzzy = &FuzzyTitle{Title: strings.ToLower(jr.HTTPTitleFuzzy.Title), MaxDistance: jr.HTTPTitleFuzzy.MaxDistance}
+		}
+	}
+
+	for _, line := range jr.HTTPBodyLine {
+		line = strings.TrimSpace(line)
+		if jr.CaseInsensitive {
+			line = strings.ToLower(line)
+		}
+		rule.BodyLines = append(rule.BodyLines, line)
 	}
-	for k, v := range jr.HTTPHeader {
-		rule.Headers[strings.ToLower(k)] = v
+
+	for _, method := range jr.HTTPMethod {
+		rule.Methods = append(rule.Methods, strings.ToUpper(method))
+	}
+
+	for _, header := range jr.HTTPHeaderPresent {
+		rule.HeadersPresent = append(rule.HeadersPresent, strings.ToLower(header))
+	}
+
+	for header, count := range jr.HTTPHeaderCount {
+		if count < 1 {
+			return Rule{}, fmt.Errorf("invalid count %d for header %s in http_header_count", count, header)
+		}
+		if rule.HeaderCounts == nil {
+			rule.HeaderCounts = make(map[string]int, len(jr.HTTPHeaderCount))
+		}
+		rule.HeaderCounts[strings.ToLower(header)] = count
+	}
+
+	for _, pattern := range jr.TLSSANContains {
+		rule.TLSSANs = append(rule.TLSSANs, strings.ToLower(pattern))
+	}
+
+	for _, header := range jr.HTTPReflectedHeader {
+		rule.ReflectedHeaders = append(rule.ReflectedHeaders, strings.ToLower(header))
+	}
+
+	for _, cookie := range jr.HTTPCookie {
+		rule.Cookies = append(rule.Cookies, strings.ToLower(cookie))
+	}
+
+	for _, requirement := range jr.TechStack {
+		rule.TechStack = append(rule.TechStack, strings.ToLower(strings.TrimSpace(requirement)))
+	}
+
+	rule.LinkRel = strings.ToLower(strings.TrimSpace(jr.HTTPLinkRel))
+	for _, pattern := range jr.HTTPLinkContains {
+		rule.LinkContains = append(rule.LinkContains, strings.ToLower(pattern))
+	}
+
+	for _, pattern := range jr.HTTPFailureContains {
+		rule.FailureContains = append(rule.FailureContains, strings.ToLower(pattern))
+	}
+
+	for _, token := range jr.HTTPVary {
+		rule.Vary = append(rule.Vary, strings.ToLower(strings.TrimSpace(token)))
+	}
+
+	for _, contentType := range jr.HTTPContentType {
+		rule.ContentTypes = append(rule.ContentTypes, strings.ToLower(contentType))
 	}
 
 	// Parse status code (single or range)
@@ -127,145 +552,480 @@ func compileRule(jr RuleJSON) (Rule, error) {
 		parts := strings.Split(jr.HTTPStatusCode, "-")
 		switch len(parts) {
 		case 1:
-			// Single status code
-			if status, err := strconv.Atoi(parts[0]); err == nil {
-				rule.StatusMin = status
-				rule.StatusMax = status
+			// Single status code, 0 matching responses without a status
+			status := 0
+			if parts[0] != "0" {
+				var err error
+				if status, err = parseStatusCode(parts[0]); err != nil {
+					return Rule{}, err
+				}
 			}
+			rule.HasStatus = true
+			rule.StatusMin = status
+			rule.StatusMax = status
 		case 2:
 			// Status code range
-			min, _ := strconv.Atoi(parts[0])
-			max, _ := strconv.Atoi(parts[1])
-			if min > 0 && max > 0 {
-				rule.StatusMin = min
-				rule.StatusMax = max
+			min, err := parseStatusCode(parts[0])
+			if err != nil {
+				return Rule{}, err
+			}
+			max, err := parseStatusCode(parts[1])
+			if err != nil {
+				return Rule{}, err
 			}
+			rule.HasStatus = true
+			rule.StatusMin = min
+			rule.StatusMax = max
 		default:
 			return Rule{}, fmt.Errorf("invalid status code format: %s", jr.HTTPStatusCode)
 		}
 	}
 
+	// Parse status class (1xx to 5xx)
+	if jr.HTTPStatusClass != "" {
+		if jr.HTTPStatusCode != "" {
+			return Rule{}, fmt.Errorf("http_status_code and http_status_class cannot be combined")
+		}
+		class := strings.ToLower(jr.HTTPStatusClass)
+		if len(class) != 3 || class[0] < '1' || class[0] > '5' || class[1:] != "xx" {
+			return Rule{}, fmt.Errorf("invalid status class format: %s", jr.HTTPStatusClass)
+		}
+		rule.HasStatus = true
+		rule.StatusMin = int(class[0]-'0') * 100
+		rule.StatusMax = rule.StatusMin + 99
+	}
+
 	// Compile body regex patterns
 	for _, pattern := range jr.HTTPBodyRegex {
-		re, err := regexp.Compile(pattern)
+		re, err := pattern.compile()
 		if err != nil {
-			return Rule{}, fmt.Errorf("invalid body regex pattern %q: %w", pattern, err)
+			return Rule{}, fmt.Errorf("invalid body regex pattern %q: %w", pattern.Pattern, err)
 		}
 		rule.BodyRegex = append(rule.BodyRegex, re)
 	}
+	rule.needsFullBody = len(rule.JSONFields) > 0 || slices.ContainsFunc(rule.BodyRegex, endSensitive)
+
+	// Compile raw headers regex patterns
+	for _, pattern := range jr.HTTPHeadersRegex {
+		re, err := pattern.compile()
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid headers regex pattern %q: %w", pattern.Pattern, err)
+		}
+		rule.RawHeaderRegex = append(rule.RawHeaderRegex, re)
+	}
+
+	// Compile title regex pattern
+	if jr.HTTPTitleRegex != nil {
+		re, err := jr.HTTPTitleRegex.compile()
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid title regex pattern %q: %w", jr.HTTPTitleRegex.Pattern, err)
+		}
+		rule.TitleRegex = re
+	}
+
+	// Compile match groups, any of which must match
+	for i, group := range jr.MatchGroups {
+		compiled, err := compileRule(group)
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid match group %d: %w", i, err)
+		}
+		rule.Groups = append(rule.Groups, compiled)
+	}
+
+	// Parse the validity window
+	var err error
+	if jr.ValidFrom != "" {
+		if rule.ValidFrom, err = time.Parse(time.RFC3339, jr.ValidFrom); err != nil {
+			return Rule{}, fmt.Errorf("invalid valid_from: %w", err)
+		}
+	}
+	if jr.ValidUntil != "" {
+		if rule.ValidUntil, err = time.Parse(time.RFC3339, jr.ValidUntil); err != nil {
+			return Rule{}, fmt.Errorf("invalid valid_until: %w", err)
+		}
+	}
+	if !rule.ValidFrom.IsZero() && !rule.ValidUntil.IsZero() && !rule.ValidUntil.After(rule.ValidFrom) {
+		return Rule{}, fmt.Errorf("valid_until %s is not after valid_from %s", jr.ValidUntil, jr.ValidFrom)
+	}
+
+	// Compile the conditions on the error page of the pair
+	if jr.On404 != nil {
+		compiled, err := compileRule(*jr.On404)
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid on_404 block: %w", err)
+		}
+		rule.On404 = &compiled
+	}
 
 	return rule, nil
 }
 
-// Match returns the names of WAF/CDN providers that match the response
+// Match returns the names of WAF/CDN providers that match the response.
+// When a match budget is configured the results may be incomplete, use
+// MatchBudgeted to know whether evaluation was cut short.
 func (m *Matcher) Match(resp Response) []string {
-	loweredHeaders := make(map[string]string)
-	for k, v := range resp.Headers {
-		loweredHeaders[strings.ToLower(k)] = v
+	matches, _ := m.MatchBudgeted(resp)
+	return matches
+}
+
+// MatchBudgeted returns the names of WAF/CDN providers that match the
+// response, and whether evaluation stopped early because the match
+// budget ran out, in which case the matches are partial
+func (m *Matcher) MatchBudgeted(resp Response) (matches []string, truncated bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matches, truncated = m.matchInput(m.newInput(resp, nil))
+	return m.resultNames(matches), truncated
+}
+
+// MatchPair returns the names of WAF/CDN providers that match a normal
+// response and the error page returned for a nonexistent path of the
+// same host. Rules with an on_404 block require it to match the error
+// page, and only match pairs. Other rules are matched against the
+// normal response.
+func (m *Matcher) MatchPair(normal, errorPage Response) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	in := m.newInput(normal, nil)
+	in.errorPage = m.newInput(errorPage, nil)
+	matches, _ := m.matchInput(in)
+	return m.resultNames(matches)
+}
+
+// MatchOnly evaluates only the rules of the named providers against the
+// response, returning those that match in the order given. Provider
+// filters do not apply. An error is returned for unknown providers.
+func (m *Matcher) MatchOnly(resp Response, providers ...string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, provider := range providers {
+		if _, ok := m.rules[provider]; !ok {
+			return nil, fmt.Errorf("unknown provider %q", provider)
+		}
 	}
-	resp.Headers = loweredHeaders
 
+	in := m.newInput(resp, nil)
+	now := m.clock()
 	var matches []string
-	for provider, rule := range m.rules {
-		if matchRule(resp, rule) {
+	for _, provider := range providers {
+		if rule := m.rules[provider]; rule.activeAt(now) && m.evalRule(provider, in, rule) {
 			matches = append(matches, provider)
 		}
 	}
+	return matches, nil
+}
+
+// MatchLimit returns at most n providers matching the response. Rules
+// are evaluated from the highest priority vendor down, as ranked by
+// Classify, and evaluation stops once n providers matched. A limit of
+// zero or less returns every match.
+func (m *Matcher) MatchLimit(resp Response, n int) []string {
+	if n <= 0 {
+		return m.Match(resp)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	in := m.newInput(resp, nil)
+	matches := m.evalRules(in, m.providerAllowed, n)
+	if names := m.resultNames(matches); len(names) == n || in.truncated {
+		return names
+	}
+	matches = m.resultNames(m.matchCustom(in, matches))
+	return matches[:min(n, len(matches))]
+}
+
+// matchInput evaluates the allowed rules, then the custom matchers,
+// against the prepared input. The caller must hold the read lock.
+func (m *Matcher) matchInput(in *input) (matches []string, truncated bool) {
+	matches = m.evalRules(in, m.providerAllowed, 0)
+	if !in.truncated {
+		matches = m.matchCustom(in, matches)
+	}
+	return matches, in.truncated
+}
+
+// evalRules evaluates the active rules of the providers accepted by
+// allowed against the prepared input, from the highest priority vendor
+// down, until the match budget runs out or, when limit is positive,
+// limit distinct result names matched. The caller must hold the read
+// lock.
+func (m *Matcher) evalRules(in *input, allowed func(provider string) bool, limit int) []string {
+	if m.matchBudget > 0 {
+		in.deadline = time.Now().Add(m.matchBudget)
+	}
+
+	now := m.clock()
+	var matches, names []string
+	for _, provider := range m.order {
+		if in.budgetSpent() {
+			break
+		}
+		rule := m.rules[provider]
+		if !allowed(provider) || !rule.activeAt(now) {
+			continue
+		}
+		if in.foldBody || in.foldHeaders {
+			rule = rule.folded(in.foldBody, in.foldHeaders)
+		}
+		if !m.evalRule(provider, in, rule) {
+			continue
+		}
+		matches = append(matches, provider)
+		if limit <= 0 {
+			continue
+		}
+		if name := m.resultName(provider); !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+		if len(names) == limit {
+			break
+		}
+	}
 	return matches
 }
 
-// matchRule checks if a response matches a specific rule
-func matchRule(resp Response, rule Rule) bool {
-	if rule.StatusMin != 0 && resp.StatusCode < rule.StatusMin {
+// resultNames converts matched providers into the names reported to
+// callers: variants are replaced with their parent when collapsing is
+// enabled, then aliases are applied, keeping the first occurrence of
+// each name. The caller must hold the read lock.
+func (m *Matcher) resultNames(matches []string) []string {
+	if !m.collapse && len(m.aliases) == 0 {
+		return matches
+	}
+	var names []string
+	for _, provider := range matches {
+		if name := m.resultName(provider); !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// resultName returns the name reported for a matched provider. The
+// caller must hold the read lock.
+func (m *Matcher) resultName(provider string) string {
+	if parent := m.rules[provider].Parent; m.collapse && parent != "" {
+		provider = parent
+	}
+	return m.alias(provider)
+}
+
+// alias returns the canonical name configured for the provider with
+// WithAliases, or the provider itself
+func (m *Matcher) alias(provider string) string {
+	if canonical, ok := m.aliases[provider]; ok {
+		return canonical
+	}
+	return provider
+}
+
+// providerAllowed reports whether the provider passes the enabled and
+// disabled provider and category filters. Rules of opt-in categories
+// only pass when one of their categories is enabled. The caller must
+// hold the read lock.
+func (m *Matcher) providerAllowed(provider string) bool {
+	if m.denyByDefault && len(m.enabledCategories) == 0 {
 		return false
 	}
-	if rule.StatusMax != 0 && resp.StatusCode > rule.StatusMax {
+	tags := m.rules[provider].Tags
+	if (len(m.enabledCategories) > 0 || optInOnly(tags)) && !slices.ContainsFunc(tags, func(tag string) bool {
+		_, ok := m.enabledCategories[tag]
+		return ok
+	}) {
 		return false
 	}
+	return m.providerEnabled(provider)
+}
 
-	// Headers check
-	for header, pattern := range rule.Headers {
-		value, exists := resp.Headers[header]
-		if !exists || !strings.Contains(value, pattern) {
+// providerEnabled reports whether the provider passes the enabled and
+// disabled provider filters. The caller must hold the read lock.
+func (m *Matcher) providerEnabled(provider string) bool {
+	if m.enabledProviders != nil {
+		if _, ok := m.enabledProviders[provider]; !ok {
 			return false
 		}
 	}
+	_, disabled := m.disabledProviders[provider]
+	return !disabled
+}
 
-	// Body contains check
-	for _, pattern := range rule.BodyContains {
-		if !strings.Contains(resp.Body, pattern) {
-			return false
-		}
+// activeAt reports whether the time falls in the validity window of the
+// rule
+func (r Rule) activeAt(now time.Time) bool {
+	if !r.ValidFrom.IsZero() && now.Before(r.ValidFrom) {
+		return false
 	}
+	return r.ValidUntil.IsZero() || now.Before(r.ValidUntil)
+}
 
-	// Body regex check
-	for _, re := range rule.BodyRegex {
-		if !re.MatchString(resp.Body) {
-			return false
-		}
+// ageInRange reports whether the Age header value is within the bounds
+// of the rule, a zero bound being unset
+func (r Rule) ageInRange(age int) bool {
+	return age >= r.AgeMin && (r.AgeMax == 0 || age <= r.AgeMax)
+}
+
+// parseStatusCode parses a status code of a status condition, which
+// must be between 100 and 599
+func parseStatusCode(value string) (int, error) {
+	status, err := strconv.Atoi(value)
+	if err != nil || status < 100 || status > 599 {
+		return 0, fmt.Errorf("invalid status code %q", value)
 	}
+	return status, nil
+}
 
-	// Title checks
-	if rule.TitleExact != "" && resp.Title != rule.TitleExact {
+// statusInRange reports whether the status code satisfies the status
+// condition of the rule
+func (r Rule) statusInRange(status int) bool {
+	if status < r.StatusMin {
 		return false
 	}
+	// A zero maximum above a non-zero minimum leaves the range open, as
+	// WithDefaultStatusRange allows
+	return status <= r.StatusMax || (r.StatusMax == 0 && r.StatusMin != 0)
+}
 
-	// Redirect check
-	if rule.RedirectCheck != nil {
-		if !matchRedirectRule(resp, *rule.RedirectCheck) {
+// bodyLengthInRange reports whether the body length is within the
+// bounds of the rule, a zero bound being unset
+func (r Rule) bodyLengthInRange(length int) bool {
+	return length >= r.BodyLengthMin && (r.BodyLengthMax == 0 || length <= r.BodyLengthMax)
+}
+
+// usesCaseInsensitive reports whether the rule or any of its groups is
+// case-insensitive
+func (r Rule) usesCaseInsensitive() bool {
+	return r.CaseInsensitive || slices.ContainsFunc(r.Groups, Rule.usesCaseInsensitive) ||
+		(r.On404 != nil && r.On404.usesCaseInsensitive())
+}
+
+// matchRule checks if a response matches a specific rule. Rules
+// without a minimum score require every signal they check to match,
+// weighted rules match once the weights of the matching signals reach
+// the minimum score.
+func (m *Matcher) matchRule(resp *input, rule Rule) bool {
+	// Rules scoped to an error page only match response pairs
+	if rule.On404 != nil {
+		errorPage := resp.errorPage
+		if errorPage == nil {
+			return false
+		}
+		errorPage.deadline = resp.deadline
+		errorPage.regexTime = resp.regexTime
+		matched := m.matchRule(errorPage, *rule.On404)
+		resp.truncated = resp.truncated || errorPage.truncated
+		if !matched {
 			return false
 		}
 	}
 
-	return true
+	var score float64
+	for _, signal := range signals {
+		checked, ok := m.evalSignal(resp, rule, signal, nil)
+		if !checked {
+			continue
+		}
+		if rule.MinScore <= 0 {
+			if !ok {
+				return false
+			}
+			continue
+		}
+		if ok {
+			score += rule.Weights[signal]
+			if score >= rule.MinScore {
+				return true
+			}
+		}
+	}
+	return rule.MinScore <= 0
 }
 
-// matchRedirectRule checks if a response matches redirect rules
-func matchRedirectRule(resp Response, redirectRule CheckRedirect) bool {
-	parsedOriginalURL, err := url.Parse(resp.RequestURL)
-	if err != nil {
+// matchTitle checks if the i-th title candidate satisfies all title
+// conditions. Regexes keep their own flags, so they are matched against
+// the original title rather than the lowercased one.
+func (m *Matcher) matchTitle(resp *input, rule Rule, titles []string, i int) bool {
+	if rule.TitleExact != "" && titles[i] != rule.TitleExact {
 		return false
 	}
-	originalPort := getPortFromURL(parsedOriginalURL)
-
-	if !slices.Contains(redirectRule.SourcePorts, originalPort) {
+	if rule.TitleRegex != nil && !m.matchRegex(resp, rule.TitleRegex, resp.titles[i]) {
 		return false
 	}
-
-	location, exists := resp.Headers["location"]
-	if !exists {
+	if rule.TitleFuzzy != nil && !rule.TitleFuzzy.match(titles[i]) {
 		return false
 	}
+	return true
+}
 
-	parsedLocation, err := url.Parse(location)
-	if err != nil {
-		return false
+// hasTitleConditions reports whether the rule checks the title
+func (r Rule) hasTitleConditions() bool {
+	return r.TitleExact != "" || r.TitleRegex != nil || r.TitleFuzzy != nil
+}
+
+// matchHeaders checks that, for every header, one of its values
+// contains one of the patterns listed for it
+func matchHeaders(headers map[string][]string, patterns map[string][]string) bool {
+	for header, alternatives := range patterns {
+		if !headerContains(headers[header], alternatives) {
+			return false
+		}
 	}
+	return true
+}
+
+// headerContains reports whether one of the values of a header contains
+// one of the patterns
+func headerContains(values []string, patterns []string) bool {
+	return slices.ContainsFunc(values, func(value string) bool {
+		return slices.ContainsFunc(patterns, func(pattern string) bool {
+			return strings.Contains(value, pattern)
+		})
+	})
+}
 
-	if !parsedLocation.IsAbs() {
-		parsedLocation.Scheme = parsedOriginalURL.Scheme
-		parsedLocation.Host = parsedOriginalURL.Host
+// mediaType returns the lowercased media type of a Content-Type header
+// value without its parameters
+func mediaType(contentType string) string {
+	parsed, _, err := mime.ParseMediaType(contentType)
+	if err != nil && parsed == "" {
+		return ""
 	}
+	return parsed
+}
 
-	targetPort := getPortFromURL(parsedLocation)
-	return slices.Contains(redirectRule.TargetPorts, targetPort)
+// NormalizeHeaders returns a copy of the headers with all keys
+// lowercased, the same normalization Match applies before matching
+func NormalizeHeaders(headers map[string]string) map[string]string {
+	normalized := make(map[string]string, len(headers))
+	for k, v := range headers {
+		normalized[strings.ToLower(k)] = v
+	}
+	return normalized
 }
 
-// getPortFromURL extracts port from URL, returning default ports for schemes if not specified
-func getPortFromURL(u *url.URL) int {
-	port := u.Port()
-	if port != "" {
-		if p, err := strconv.Atoi(port); err == nil {
-			return p
-		}
+// matchRegex runs a regex against the value, searching at most the
+// first maxRegexInput bytes. Once the match budget is spent regexes are
+// no longer run and report no match, marking the evaluation truncated.
+func (m *Matcher) matchRegex(resp *input, re *regexp.Regexp, value string) bool {
+	if resp.regexTime != nil {
+		start := time.Now()
+		defer func() { *resp.regexTime += time.Since(start) }()
 	}
+	if resp.budgetSpent() {
+		return false
+	}
+	return re.MatchString(m.regexInput(value))
+}
 
-	switch u.Scheme {
-	case "https":
-		return 443
-	case "http":
-		return 80
-	default:
-		return 0
+// regexInput returns the prefix of the value regexes are matched
+// against, as bounded by WithMaxRegexInput
+func (m *Matcher) regexInput(value string) string {
+	if m.maxRegexInput > 0 && len(value) > m.maxRegexInput {
+		return value[:m.maxRegexInput]
 	}
+	return value
 }
@@ -1,7 +1,13 @@
 package cleanhttp
 
 import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -167,3 +173,742 @@ func TestNewMatcherErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestRegexComplexityGuard(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	err = matcher.AddRules([]byte(`{"services": {"complex": {"http_body_regex": ["(\\w+\\s*){1000}"]}}}`))
+	require.Error(t, err, "overly complex regex should be rejected")
+}
+
+func TestMaxRegexInput(t *testing.T) {
+	rules := []byte(`{"services": {
+		"slow": {"http_body_regex": ["(\\w+\\s*){200}xyz"]},
+		"needle": {"http_body_regex": ["needle"]}
+	}}`)
+
+	capped, err := newMatcher(rules, []Option{WithMaxRegexInput(4 << 10)})
+	require.NoError(t, err)
+
+	start := time.Now()
+	got := capped.Match(Response{StatusCode: 200, Body: strings.Repeat("abcd ", 100000)})
+	require.Less(t, time.Since(start), time.Second, "regex evaluation should be bounded by the input cap")
+	require.Empty(t, got)
+
+	resp := Response{StatusCode: 200, Body: strings.Repeat("a", 8<<10) + "needle"}
+	require.Empty(t, capped.Match(resp))
+
+	unbounded, err := newMatcher(rules, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"needle"}, unbounded.Match(resp), "values are not capped by default")
+}
+
+func TestMatchGroups(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	err = matcher.AddRules([]byte(`{"services": {"grouped": {
+		"http_status_code": "403",
+		"match_groups": [
+			{"http_header": {"Server": "vendor-a"}, "http_body": ["blocked by a"]},
+			{"http_header": {"X-Vendor": "b"}, "http_title": "Blocked by B"}
+		]
+	}}}`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		response Response
+		want     []string
+	}{
+		{
+			name: "second group matches",
+			response: Response{
+				StatusCode: 403,
+				Headers:    map[string]string{"Server": "nginx", "X-Vendor": "b"},
+				Title:      "Blocked by B",
+			},
+			want: []string{"grouped"},
+		},
+		{
+			name: "group partially matches",
+			response: Response{
+				StatusCode: 403,
+				Headers:    map[string]string{"Server": "vendor-a", "X-Vendor": "b"},
+				Title:      "Forbidden",
+			},
+			want: nil,
+		},
+		{
+			name: "top-level condition fails",
+			response: Response{
+				StatusCode: 200,
+				Headers:    map[string]string{"X-Vendor": "b"},
+				Title:      "Blocked by B",
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matcher.Match(tt.response)
+			require.ElementsMatch(t, got, tt.want, "could not match %v", tt.response)
+		})
+	}
+}
+
+func TestDefaultRules(t *testing.T) {
+	rules := DefaultRules()
+	require.NotEmpty(t, rules)
+
+	var services ServicesJSON
+	require.NoError(t, json.Unmarshal(rules, &services))
+	require.NotEmpty(t, services.Services)
+
+	for i := range rules {
+		rules[i] = 0
+	}
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.Len(t, matcher.rules, len(services.Services))
+}
+
+func TestMatchProtocol(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	err = matcher.AddRules([]byte(`{"services": {"h2_edge": {
+		"http_header": {"Server": "edge"},
+		"alpn": "h2",
+		"h2_settings": {"MAX_CONCURRENT_STREAMS": 100}
+	}}}`))
+	require.NoError(t, err)
+
+	resp := Response{
+		StatusCode: 200,
+		Headers:    map[string]string{"Server": "edge"},
+		ALPN:       "h2",
+		H2Settings: map[string]uint32{"MAX_CONCURRENT_STREAMS": 100, "INITIAL_WINDOW_SIZE": 65536},
+	}
+	require.Equal(t, []string{"h2_edge"}, matcher.Match(resp))
+
+	resp.ALPN = "http/1.1"
+	require.Empty(t, matcher.Match(resp))
+
+	resp.ALPN = "h2"
+	resp.H2Settings["MAX_CONCURRENT_STREAMS"] = 250
+	require.Empty(t, matcher.Match(resp))
+}
+
+func TestMatchConnectionEvents(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("goaway_edge", RuleJSON{HTTPStatusCode: "403", GoAway: true}))
+	require.NoError(t, matcher.AddRule("reset_edge", RuleJSON{ConnReset: true}))
+
+	require.Equal(t, []string{"goaway_edge"}, matcher.Match(Response{StatusCode: 403, GoAway: true}))
+	require.Empty(t, matcher.Match(Response{StatusCode: 200, GoAway: true}))
+	require.Empty(t, matcher.Match(Response{StatusCode: 403}))
+	require.Equal(t, []string{"reset_edge"}, matcher.Match(Response{ConnReset: true}))
+}
+
+func TestMatchFailureReason(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"dropped_by_waf": {"http_failure_contains": ["Connection reset by peer"]},
+		"tls_failure": {"http_failure_contains": ["tls", "handshake"]}
+	}}`), nil)
+	require.NoError(t, err)
+
+	resp := Response{
+		RequestURL:    "https://example.com/?file=../../etc/passwd",
+		FailureReason: "read tcp 10.0.0.1:51234->93.184.216.34:443: read: connection reset by peer",
+	}
+	require.Equal(t, []string{"dropped_by_waf"}, matcher.Match(resp))
+
+	matched, reasons := matcher.Explain(Response{FailureReason: "EOF"}, "dropped_by_waf")
+	require.False(t, matched)
+	require.Equal(t, []string{`failure reason contains "connection reset by peer": failed`}, reasons)
+
+	require.Equal(t, []string{"tls_failure"}, matcher.Match(Response{FailureReason: "remote error: tls: handshake failure"}))
+	require.Empty(t, matcher.Match(Response{StatusCode: 200}))
+}
+
+func TestMatchHeaderPresent(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	err = matcher.AddRules([]byte(`{"services": {"cloudflare_ray": {"http_header_present": ["CF-RAY"]}}}`))
+	require.NoError(t, err)
+
+	present := Response{StatusCode: 200, Headers: map[string]string{"cf-ray": "8a1b2c3d4e5f-AMS"}}
+	require.Equal(t, []string{"cloudflare_ray"}, matcher.Match(present))
+
+	empty := Response{StatusCode: 200, Headers: map[string]string{"CF-Ray": ""}}
+	require.Equal(t, []string{"cloudflare_ray"}, matcher.Match(empty))
+
+	absent := Response{StatusCode: 200, Headers: map[string]string{"server": "cloudflare"}}
+	require.Empty(t, matcher.Match(absent))
+}
+
+func TestNormalizeHeaders(t *testing.T) {
+	require.Equal(t,
+		map[string]string{"content-type": "text/html", "x-cache": "HIT"},
+		NormalizeHeaders(map[string]string{"Content-Type": "text/html", "X-CACHE": "HIT"}),
+	)
+
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	err = matcher.AddRules([]byte(`{"services": {"html_block": {"http_header": {"content-type": "text/html"}}}}`))
+	require.NoError(t, err)
+
+	for _, key := range []string{"Content-Type", "content-type"} {
+		headers := map[string]string{key: "text/html; charset=utf-8"}
+		require.Equal(t, []string{"html_block"}, matcher.Match(Response{Headers: headers}), key)
+		require.Equal(t, []string{"html_block"}, matcher.Match(Response{Headers: NormalizeHeaders(headers)}), key)
+	}
+}
+
+func TestMatchWebSocketUpgrade(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	resp := Response{
+		StatusCode: 101,
+		Headers: map[string]string{
+			"Server":     "cloudflare",
+			"Upgrade":    "websocket",
+			"Connection": "Upgrade",
+		},
+	}
+	require.Equal(t, []string{"cloudflare_websocket"}, matcher.Match(resp))
+
+	resp.StatusCode = 200
+	require.Empty(t, matcher.Match(resp))
+}
+
+func TestGetPortFromURL(t *testing.T) {
+	tests := map[string]int{
+		"http://example.com/":       80,
+		"https://example.com/":      443,
+		"ws://example.com/socket":   80,
+		"wss://example.com/socket":  443,
+		"wss://example.com:8443/":   8443,
+		"gopher://example.com/path": 0,
+	}
+	for rawURL, want := range tests {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		require.Equal(t, want, getPortFromURL(u), rawURL)
+	}
+}
+
+func TestDefaultStatusRange(t *testing.T) {
+	rules := []byte(`{"services": {
+		"title_only": {"http_title": "Access Denied"},
+		"explicit_status": {"http_status_code": "200", "http_title": "Access Denied"}
+	}}`)
+	resp := Response{StatusCode: 200, Title: "Access Denied"}
+
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRules(rules))
+	require.ElementsMatch(t, []string{"title_only", "explicit_status"}, matcher.Match(resp))
+
+	gated, err := NewMatcher("", WithDefaultStatusRange(300, 599))
+	require.NoError(t, err)
+	require.NoError(t, gated.AddRules(rules))
+	require.Equal(t, []string{"explicit_status"}, gated.Match(resp))
+
+	resp.StatusCode = 403
+	require.Equal(t, []string{"title_only"}, gated.Match(resp))
+}
+
+func TestMissingStatusCode(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"unavailable": {"http_status_code": "503", "http_body": ["maintenance"]},
+		"no_status": {"http_status_code": "0"},
+		"any_status": {"http_body": ["maintenance"]}
+	}}`), nil)
+	require.NoError(t, err)
+
+	require.ElementsMatch(t, []string{"no_status", "any_status"}, matcher.Match(Response{Body: "maintenance"}))
+	require.ElementsMatch(t, []string{"unavailable", "any_status"}, matcher.Match(Response{StatusCode: 503, Body: "maintenance"}))
+
+	matched, reasons := matcher.Explain(Response{Body: "maintenance"}, "unavailable")
+	require.False(t, matched)
+	require.Contains(t, reasons, "status 0 is 503: failed")
+}
+
+func TestMalformedStatusCode(t *testing.T) {
+	for _, status := range []string{"50x", "0-599", "500-", "-599", "600", "99", "500-5xx", " 503"} {
+		t.Run(status, func(t *testing.T) {
+			_, err := compileRule(RuleJSON{HTTPStatusCode: status})
+			require.Error(t, err)
+		})
+	}
+	for _, status := range []string{"0", "100", "599", "500-599"} {
+		_, err := compileRule(RuleJSON{HTTPStatusCode: status})
+		require.NoError(t, err, status)
+	}
+}
+
+func TestMatchContentType(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	err = matcher.AddRules([]byte(`{"services": {"html_page": {"http_content_type": ["text/html"]}}}`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		contentType string
+		want        []string
+	}{
+		{contentType: "text/html; charset=utf-8", want: []string{"html_page"}},
+		{contentType: "TEXT/HTML", want: []string{"html_page"}},
+		{contentType: "text/html-fragment", want: nil},
+		{contentType: "application/json", want: nil},
+		{contentType: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			got := matcher.Match(Response{Headers: map[string]string{"Content-Type": tt.contentType}})
+			require.ElementsMatch(t, tt.want, got)
+		})
+	}
+}
+
+func TestAddRule(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	err = matcher.AddRule("sucuri", RuleJSON{
+		HTTPStatusCode:    "403",
+		HTTPHeaderPresent: []string{"X-Sucuri-ID"},
+		HTTPTitle:         "Sucuri WebSite Firewall - Access Denied",
+	})
+	require.NoError(t, err)
+
+	resp := Response{
+		StatusCode: 403,
+		Headers:    map[string]string{"X-Sucuri-ID": "11005"},
+		Title:      "Sucuri WebSite Firewall - Access Denied",
+	}
+	require.Equal(t, []string{"sucuri"}, matcher.Match(resp))
+
+	err = matcher.AddRule("broken", RuleJSON{HTTPBodyRegex: []RegexPattern{{Pattern: "("}}})
+	var ruleErr *RuleError
+	require.ErrorAs(t, err, &ruleErr)
+	require.Equal(t, "broken", ruleErr.Provider)
+}
+
+func TestCaseInsensitiveRule(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("ci_block", RuleJSON{
+		HTTPHeader:      map[string]HeaderPatterns{"Server": {"BlockGuard"}},
+		HTTPBody:        []string{"Access Denied"},
+		HTTPTitle:       "Request Blocked",
+		CaseInsensitive: true,
+	}))
+	require.NoError(t, matcher.AddRule("cs_block", RuleJSON{
+		HTTPBody: []string{"Access Denied"},
+	}))
+
+	resp := Response{
+		Headers: map[string]string{"server": "blockguard/2.1"},
+		Body:    "<h1>ACCESS DENIED</h1>",
+		Title:   "REQUEST BLOCKED",
+	}
+	require.Equal(t, []string{"ci_block"}, matcher.Match(resp))
+
+	results := matcher.MatchDetailed(resp).Results
+	require.Len(t, results, 1)
+	require.Equal(t, []Span{{Start: 4, End: 17, Text: "ACCESS DENIED"}}, results[0].BodyMatches)
+}
+
+// benchmarkCaseInsensitiveResponse is a large block page that none of
+// the benchmark rules match, so that every rule reads the whole body
+var benchmarkCaseInsensitiveResponse = Response{
+	StatusCode: 403,
+	Headers:    map[string]string{"Server": "nginx"},
+	Title:      "ACCESS DENIED",
+	Body:       strings.Repeat("<p>Request Blocked By Vendor</p>", 2000),
+}
+
+func TestCaseInsensitiveTitleRule(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("ci_regex", RuleJSON{
+		HTTPTitleRegex:  &RegexPattern{Pattern: `^Access Denied`},
+		CaseInsensitive: true,
+	}))
+	require.NoError(t, matcher.AddRule("ci_fuzzy", RuleJSON{
+		HTTPTitleFuzzy:  &FuzzyTitle{Title: "Access Denied", MaxDistance: 1},
+		CaseInsensitive: true,
+	}))
+
+	tests := []struct {
+		title string
+		want  []string
+	}{
+		{title: "Access Denied", want: []string{"ci_fuzzy", "ci_regex"}},
+		{title: "Access Denied - Blocked", want: []string{"ci_regex"}},
+		{title: "ACCESS DENIED!", want: []string{"ci_fuzzy"}},
+		{title: "Welcome", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			require.ElementsMatch(t, tt.want, matcher.Match(Response{StatusCode: 403, Title: tt.title}))
+		})
+	}
+}
+
+func BenchmarkMatchCaseInsensitive(b *testing.B) {
+	matcher, err := NewMatcher("")
+	require.NoError(b, err)
+	for i := 0; i < 10; i++ {
+		require.NoError(b, matcher.AddRule("ci_"+strconv.Itoa(i), RuleJSON{
+			HTTPBody:        []string{"blocked by vendor " + strconv.Itoa(i)},
+			HTTPTitle:       "Access Denied",
+			CaseInsensitive: true,
+		}))
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matcher.Match(benchmarkCaseInsensitiveResponse)
+	}
+}
+
+// lowercasingMatcher lowercases the response on every call, the pattern
+// case-insensitive rules avoid by sharing one lowercased response
+type lowercasingMatcher struct {
+	provider, body, title string
+}
+
+func (l lowercasingMatcher) Match(resp Response) (string, bool) {
+	return l.provider, strings.Contains(strings.ToLower(resp.Body), l.body) && strings.ToLower(resp.Title) == l.title
+}
+
+// BenchmarkMatchCaseInsensitivePerRule lowercases the response once per
+// rule, the baseline of BenchmarkMatchCaseInsensitive
+func BenchmarkMatchCaseInsensitivePerRule(b *testing.B) {
+	matcher, err := NewMatcher("")
+	require.NoError(b, err)
+	for i := 0; i < 10; i++ {
+		matcher.RegisterCustom(lowercasingMatcher{
+			provider: "ci_" + strconv.Itoa(i),
+			body:     "blocked by vendor " + strconv.Itoa(i),
+			title:    "access denied",
+		})
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matcher.Match(benchmarkCaseInsensitiveResponse)
+	}
+}
+
+func TestMatchRawHeadersRegex(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("ordered_edge", RuleJSON{
+		HTTPHeadersRegex: []RegexPattern{{Pattern: `(?m)^Server: edge\r\nX-Edge-Id: \w+\r$`}},
+	}))
+
+	resp := Response{
+		StatusCode: 200,
+		RawHeaders: "HTTP/1.1 200 OK\r\nServer: edge\r\nX-Edge-Id: abc123\r\nContent-Length: 0\r\n",
+	}
+	require.Equal(t, []string{"ordered_edge"}, matcher.Match(resp))
+
+	resp.RawHeaders = "HTTP/1.1 200 OK\r\nX-Edge-Id: abc123\r\nServer: edge\r\nContent-Length: 0\r\n"
+	require.Empty(t, matcher.Match(resp))
+}
+
+func TestMatchBudget(t *testing.T) {
+	// Each slow regex only matches at the end of the body and runs for
+	// far longer than the budget, so the budget runs out during the
+	// first rule and the others are skipped
+	rules := []byte(`{"services": {}}`)
+	for _, budget := range []time.Duration{time.Microsecond, time.Minute} {
+		matcher, err := newMatcher(rules, []Option{WithMatchBudget(budget)})
+		require.NoError(t, err)
+		for i := 0; i < 5; i++ {
+			require.NoError(t, matcher.AddRule("slow_"+strconv.Itoa(i), RuleJSON{
+				HTTPBodyRegex: []RegexPattern{{Pattern: `(\w+\s*){200}xyz|end` + strconv.Itoa(i)}},
+			}))
+		}
+		resp := Response{StatusCode: 200, Body: strings.Repeat("abcd ", 400) + "end0 end1 end2 end3 end4"}
+
+		matches, truncated := matcher.MatchBudgeted(resp)
+		if budget == time.Minute {
+			require.False(t, truncated)
+			require.Len(t, matches, 5)
+			continue
+		}
+		require.True(t, truncated)
+		require.LessOrEqual(t, len(matches), 1, "evaluation should stop once the budget is spent")
+	}
+}
+
+func TestMatchASN(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("cloudflare_asn", RuleJSON{ASN: []int{13335, 209242}}))
+	require.NoError(t, matcher.AddRule("cloudflare_asn_server", RuleJSON{
+		ASN:         []int{13335},
+		OrgContains: "cloudflare",
+		HTTPHeader:  map[string]HeaderPatterns{"Server": {"cloudflare"}},
+	}))
+
+	tests := []struct {
+		name     string
+		response Response
+		want     []string
+	}{
+		{
+			name:     "asn only",
+			response: Response{StatusCode: 200, ASN: 209242},
+			want:     []string{"cloudflare_asn"},
+		},
+		{
+			name: "asn and header",
+			response: Response{
+				StatusCode: 200,
+				ASN:        13335,
+				ASNOrg:     "CLOUDFLARENET",
+				Headers:    map[string]string{"Server": "cloudflare"},
+			},
+			want: []string{"cloudflare_asn", "cloudflare_asn_server"},
+		},
+		{
+			name:     "no asn supplied",
+			response: Response{StatusCode: 200, Headers: map[string]string{"Server": "cloudflare"}},
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ElementsMatch(t, tt.want, matcher.Match(tt.response))
+		})
+	}
+}
+
+func TestMatchTitleCandidates(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	resp := Response{
+		StatusCode: 400,
+		Titles:     []string{"Loading", "Invalid URL"},
+		Body:       "The requested URL \"[no URL]\", is invalid.",
+		Headers:    map[string]string{"server": "AkamaiGHost"},
+	}
+	require.Equal(t, []string{"akamai"}, matcher.Match(resp))
+
+	resp.Title = "Invalid URL"
+	resp.Titles = []string{"Loading"}
+	require.Equal(t, []string{"akamai"}, matcher.Match(resp))
+
+	resp.Title = ""
+	require.Empty(t, matcher.Match(resp))
+}
+
+func TestMatchHeaderPairs(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("google_via", RuleJSON{
+		HTTPHeader: map[string]HeaderPatterns{"Via": {"1.1 google"}},
+	}))
+	require.NoError(t, matcher.AddRule("joined_via", RuleJSON{
+		HTTPHeader: map[string]HeaderPatterns{"Via": {"1.1 google, 1.1 varnish"}},
+	}))
+
+	pairs := [][2]string{
+		{"Server", "nginx"},
+		{"Via", "1.1 google"},
+		{"via", "1.1 varnish"},
+	}
+
+	// A map keeps a single value per header, losing the first Via
+	merged := map[string]string{}
+	for _, pair := range pairs {
+		merged[strings.ToLower(pair[0])] = pair[1]
+	}
+	require.Empty(t, matcher.Match(Response{StatusCode: 200, Headers: merged}))
+
+	got := matcher.Match(Response{StatusCode: 200, Headers: merged, HeaderPairs: pairs})
+	require.Equal(t, []string{"google_via"}, got)
+}
+
+func TestMatchOnly(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	resp := Response{
+		StatusCode: 400,
+		Headers:    map[string]string{"Server": "AkamaiGHost"},
+		Title:      "Invalid URL",
+		Body:       "The requested URL \"&#91;no&#32;URL&#93;\", is invalid.",
+	}
+	got, err := matcher.MatchOnly(resp, "akamai")
+	require.NoError(t, err)
+	require.Equal(t, []string{"akamai"}, got)
+
+	got, err = matcher.MatchOnly(resp, "cloudflare", "cloudfront")
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	_, err = matcher.MatchOnly(resp, "akamai", "missing")
+	require.ErrorContains(t, err, `unknown provider "missing"`)
+}
+
+func TestHTTPStatusClass(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("client_error", RuleJSON{HTTPStatusClass: "4xx"}))
+
+	for status, want := range map[int][]string{
+		403: {"client_error"},
+		404: {"client_error"},
+		399: nil,
+		500: nil,
+	} {
+		require.Equal(t, want, matcher.Match(Response{StatusCode: status}), status)
+	}
+
+	for _, rule := range []RuleJSON{
+		{HTTPStatusClass: "6xx"},
+		{HTTPStatusClass: "40x"},
+		{HTTPStatusClass: "4xx", HTTPStatusCode: "403"},
+	} {
+		require.Error(t, matcher.AddRule("invalid", rule), rule.HTTPStatusClass)
+	}
+}
+
+func TestMatchPair(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"sucuri": {
+			"http_header": {"Server": "Sucuri"},
+			"on_404": {"http_status_code": "404", "http_title": "Sucuri WebSite Firewall - Access Denied"}
+		}
+	}}`), nil)
+	require.NoError(t, err)
+
+	normal := Response{StatusCode: 200, Headers: map[string]string{"Server": "Sucuri/Cloudproxy"}}
+	blocked := Response{StatusCode: 404, Title: "Sucuri WebSite Firewall - Access Denied"}
+	plain := Response{StatusCode: 404, Title: "Not Found"}
+
+	require.Equal(t, []string{"sucuri"}, matcher.MatchPair(normal, blocked))
+	require.Empty(t, matcher.MatchPair(normal, plain))
+	require.Empty(t, matcher.Match(normal), "on_404 rules only match pairs")
+}
+
+func TestMatchLimit(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"cloudflare_block": {"parent": "cloudflare", "http_status_code": "403"},
+		"akamai_block": {"parent": "akamai", "http_status_code": "403"},
+		"cloudfront_block": {"parent": "cloudfront", "http_status_code": "403"},
+		"origin_block": {"http_status_code": "403"}
+	}}`), nil)
+	require.NoError(t, err)
+
+	resp := Response{StatusCode: 403}
+	require.Len(t, matcher.Match(resp), 4)
+	require.Equal(t, []string{"cloudflare_block", "akamai_block"}, matcher.MatchLimit(resp, 2))
+	require.Equal(t, []string{"cloudflare_block"}, matcher.MatchLimit(resp, 1))
+	require.Len(t, matcher.MatchLimit(resp, 10), 4)
+	require.Len(t, matcher.MatchLimit(resp, 0), 4)
+
+	collapsing, err := newMatcher([]byte(`{"services": {
+		"cloudflare": {"http_status_code": "403"},
+		"cloudflare_block": {"parent": "cloudflare", "http_status_code": "403"},
+		"akamai_block": {"parent": "akamai", "http_status_code": "403"}
+	}}`), []Option{WithCollapseVariants(true)})
+	require.NoError(t, err)
+	require.Equal(t, []string{"cloudflare", "akamai"}, collapsing.MatchLimit(resp, 2))
+}
+
+func TestSchemaVersion(t *testing.T) {
+	current := []byte(`{"version": 1, "services": {"vendor": {"http_status_code": "403"}}}`)
+	unversioned := []byte(`{"services": {"vendor": {"http_status_code": "403"}}}`)
+	newer := []byte(`{"version": 2, "services": {"vendor": {"http_status_code": "403"}}}`)
+	newerFields := []byte(`{"version": 2, "services": {"vendor": {"http_status_code": "403", "http_future": true}}}`)
+
+	for _, strict := range []bool{false, true} {
+		matcher, err := NewMatcher("", WithStrict(strict))
+		require.NoError(t, err)
+
+		require.NoError(t, matcher.AddRules(current))
+		require.NoError(t, matcher.AddRules(unversioned))
+		require.ErrorContains(t, matcher.AddRules(newer), "rules schema version 2 is newer than the supported version 1")
+	}
+
+	strictMatcher, err := NewMatcher("", WithStrict(true))
+	require.NoError(t, err)
+	err = strictMatcher.AddRules(newerFields)
+	require.ErrorContains(t, err, "rules schema version 2 is newer")
+	require.ErrorContains(t, err, "http_future")
+}
+
+func TestDefaultMatcherConcurrent(t *testing.T) {
+	resp := Response{
+		StatusCode: 503,
+		Headers:    map[string]string{"Server": "cloudflare"},
+		Body:       "error code: 1020",
+	}
+
+	var wg sync.WaitGroup
+	results := make([][]string, 16)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = DefaultMatcher().Match(resp)
+		}()
+	}
+	wg.Wait()
+
+	for _, got := range results {
+		require.Equal(t, []string{"cloudflare"}, got)
+	}
+	require.Same(t, DefaultMatcher(), DefaultMatcher())
+}
+
+func TestBodyLength(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"sized_block": {"http_status_code": "403", "http_body_length_min": 100, "http_body_length_max": 200}
+	}}`), nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{name: "within window", body: strings.Repeat("x", 150), want: []string{"sized_block"}},
+		{name: "lower bound", body: strings.Repeat("x", 100), want: []string{"sized_block"}},
+		{name: "too short", body: strings.Repeat("x", 99), want: nil},
+		{name: "too long", body: strings.Repeat("x", 201), want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, matcher.Match(Response{StatusCode: 403, Body: tt.body}))
+		})
+	}
+
+	// Streaming matches fall back to the Content-Length header
+	meta := ResponseMeta{StatusCode: 403, Headers: map[string]string{"Content-Length": "180"}}
+	matches, ok := matcher.MatchEarly(meta, []byte("xxxx"))
+	require.True(t, ok)
+	require.Equal(t, []string{"sized_block"}, matches)
+	_, ok = matcher.MatchEarly(ResponseMeta{StatusCode: 403}, []byte("xxxx"))
+	require.False(t, ok)
+
+	_, err = newMatcher([]byte(`{"services": {"bad": {"http_body_length_min": 10, "http_body_length_max": 5}}}`), nil)
+	require.ErrorContains(t, err, "http_body_length_min 10 exceeds http_body_length_max 5")
+}
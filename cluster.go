@@ -0,0 +1,90 @@
+package cleanhttp
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+)
+
+// DefaultClusterSamples is the number of samples a Clusterer keeps per
+// cluster when none is given
+const DefaultClusterSamples = 3
+
+// Cluster is a group of responses sharing the same fingerprint
+type Cluster struct {
+	Fingerprint string
+	// Count is the number of responses added to the cluster
+	Count int
+	// Samples holds the first responses added to the cluster
+	Samples []Response
+}
+
+// Clusterer groups responses no rule matched by their Fingerprint, so
+// that recurring unknown pages can be turned into new rules. It is safe
+// for concurrent use.
+type Clusterer struct {
+	mu         sync.Mutex
+	maxSamples int
+	clusters   map[string]*Cluster
+}
+
+// NewClusterer returns a Clusterer keeping up to maxSamples
+// representative responses per cluster, DefaultClusterSamples when
+// zero or less
+func NewClusterer(maxSamples int) *Clusterer {
+	if maxSamples <= 0 {
+		maxSamples = DefaultClusterSamples
+	}
+	return &Clusterer{maxSamples: maxSamples, clusters: make(map[string]*Cluster)}
+}
+
+// Add adds the response to the cluster of its fingerprint
+func (c *Clusterer) Add(resp Response) {
+	fingerprint := Fingerprint(resp)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cluster, ok := c.clusters[fingerprint]
+	if !ok {
+		cluster = &Cluster{Fingerprint: fingerprint}
+		c.clusters[fingerprint] = cluster
+	}
+	cluster.Count++
+	if len(cluster.Samples) < c.maxSamples {
+		cluster.Samples = append(cluster.Samples, resp)
+	}
+}
+
+// Observe matches the response and adds it to its cluster when no
+// provider matched, returning the matches
+func (c *Clusterer) Observe(m *Matcher, resp Response) []string {
+	matches := m.Match(resp)
+	if len(matches) == 0 {
+		c.Add(resp)
+	}
+	return matches
+}
+
+// Largest returns up to n clusters with the most responses, largest
+// first, or every cluster when n is zero or less
+func (c *Clusterer) Largest(n int) []Cluster {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clusters := make([]Cluster, 0, len(c.clusters))
+	for _, cluster := range c.clusters {
+		clusters = append(clusters, Cluster{
+			Fingerprint: cluster.Fingerprint,
+			Count:       cluster.Count,
+			Samples:     slices.Clone(cluster.Samples),
+		})
+	}
+	slices.SortFunc(clusters, func(a, b Cluster) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Fingerprint, b.Fingerprint))
+	})
+	if n > 0 && len(clusters) > n {
+		clusters = clusters[:n]
+	}
+	return clusters
+}
@@ -0,0 +1,39 @@
+package cleanhttp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterer(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	clusterer := NewClusterer(2)
+
+	for i := 0; i < 4; i++ {
+		resp := Response{
+			StatusCode: 403,
+			Headers:    map[string]string{"Server": "edgeguard", "X-Request-Id": fmt.Sprintf("req-%d", i), "Date": "Mon, 01 Jan 2026 00:00:0" + fmt.Sprint(i) + " GMT"},
+			Title:      fmt.Sprintf("Blocked request %d", i),
+			Body:       fmt.Sprintf("<h1>Request blocked</h1><p>Incident identifier %d0%d</p>", i, i),
+		}
+		require.Empty(t, clusterer.Observe(matcher, resp))
+	}
+	require.Empty(t, clusterer.Observe(matcher, Response{StatusCode: 200, Title: "Home", Body: "<h1>Welcome home</h1>"}))
+	require.NotEmpty(t, clusterer.Observe(matcher, Response{
+		StatusCode: 503,
+		Headers:    map[string]string{"Server": "cloudflare"},
+		Body:       "error code: 1020",
+	}))
+
+	clusters := clusterer.Largest(0)
+	require.Len(t, clusters, 2)
+	require.Equal(t, 4, clusters[0].Count)
+	require.Len(t, clusters[0].Samples, 2)
+	require.Equal(t, "Blocked request 0", clusters[0].Samples[0].Title)
+	require.Equal(t, 1, clusters[1].Count)
+
+	require.Len(t, clusterer.Largest(1), 1)
+}
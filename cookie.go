@@ -0,0 +1,41 @@
+package cleanhttp
+
+import (
+	"slices"
+	"strings"
+)
+
+// cookieNames returns the lowercased names of the cookies set by
+// Set-Cookie header values. Values joined with commas are split again,
+// skipping the parts that belong to an Expires date.
+func cookieNames(values []string) []string {
+	var names []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			pair, _, _ := strings.Cut(part, ";")
+			name, _, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || name == "" || strings.ContainsAny(name, " \t") {
+				continue
+			}
+			names = append(names, strings.ToLower(name))
+		}
+	}
+	return names
+}
+
+// matchCookies checks that a cookie is set for every pattern. Patterns
+// ending with * match cookie names by prefix.
+func matchCookies(names []string, patterns []string) bool {
+	for _, pattern := range patterns {
+		prefix, wildcard := strings.CutSuffix(pattern, "*")
+		if !slices.ContainsFunc(names, func(name string) bool {
+			if wildcard {
+				return strings.HasPrefix(name, prefix)
+			}
+			return name == pattern
+		}) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,21 @@
+package cleanhttp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchCookiesMultiValue(t *testing.T) {
+	matcher, err := NewMatcher("", WithEnabledCategories(CategoryBotManagement))
+	require.NoError(t, err)
+
+	header := http.Header{"Set-Cookie": {"session=1; Path=/", "__pxvid=f00; Path=/"}}
+	require.Equal(t, []string{"perimeterx"}, matcher.MatchHeader(200, header, "", ""))
+}
+
+func TestCookieNames(t *testing.T) {
+	require.Equal(t, []string{"a", "b"}, cookieNames([]string{"a=1; Expires=Thu, 01 Jan 2026 00:00:00 GMT; Path=/, B=2"}))
+	require.Empty(t, cookieNames([]string{"", "invalid"}))
+}
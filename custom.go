@@ -0,0 +1,32 @@
+package cleanhttp
+
+import "slices"
+
+// CustomMatcher implements detection logic the rule format cannot
+// express. It receives the response with lowercased header names and
+// reports the provider it detected.
+type CustomMatcher interface {
+	Match(resp Response) (provider string, ok bool)
+}
+
+// RegisterCustom adds a custom matcher evaluated after the rules on
+// every match. Its providers are subject to the provider filters.
+func (m *Matcher) RegisterCustom(custom CustomMatcher) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.custom = append(m.custom, custom)
+}
+
+// matchCustom appends the providers detected by the custom matchers to
+// matches. The caller must hold the read lock.
+func (m *Matcher) matchCustom(in *input, matches []string) []string {
+	for _, custom := range m.custom {
+		provider, ok := custom.Match(in.Response)
+		if !ok || !m.providerAllowed(provider) || slices.Contains(matches, provider) {
+			continue
+		}
+		matches = append(matches, provider)
+	}
+	return matches
+}
@@ -0,0 +1,49 @@
+package cleanhttp
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// entropyMatcher detects a provider setting a high entropy session cookie
+type entropyMatcher struct{}
+
+func (entropyMatcher) Match(resp Response) (string, bool) {
+	cookie, ok := resp.Headers["set-cookie"]
+	if !ok {
+		return "", false
+	}
+	value, _, _ := strings.Cut(cookie, ";")
+	_, value, _ = strings.Cut(value, "=")
+
+	counts := make(map[rune]int)
+	for _, r := range value {
+		counts[r]++
+	}
+	var entropy float64
+	for _, count := range counts {
+		p := float64(count) / float64(len(value))
+		entropy -= p * math.Log2(p)
+	}
+	return "entropy_bot_manager", entropy > 4
+}
+
+func TestRegisterCustom(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	matcher.RegisterCustom(entropyMatcher{})
+
+	got := matcher.Match(Response{StatusCode: 200, Headers: map[string]string{"Set-Cookie": "sid=Zq8Xv2LmP4nR7tYwK9bC3hJ6; Path=/"}})
+	require.Equal(t, []string{"entropy_bot_manager"}, got)
+
+	got = matcher.Match(Response{StatusCode: 200, Headers: map[string]string{"Set-Cookie": "sid=aaaaaaaa; Path=/"}})
+	require.Empty(t, got)
+
+	filtered, err := NewMatcher("", WithDisabledProviders("entropy_bot_manager"))
+	require.NoError(t, err)
+	filtered.RegisterCustom(entropyMatcher{})
+	require.Empty(t, filtered.Match(Response{Headers: map[string]string{"Set-Cookie": "sid=Zq8Xv2LmP4nR7tYwK9bC3hJ6"}}))
+}
@@ -0,0 +1,250 @@
+package cleanhttp
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Span is a region of the response body that satisfied a body condition
+type Span struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+// MatchResult describes a provider match along with its evidence
+type MatchResult struct {
+	ID          string
+	Provider    string
+	Parent      string
+	BodyMatches []Span
+
+	// ConditionsMatched and ConditionsTotal count the conditions of the
+	// rule that matched, and all of them, including those of the match
+	// group that matched. Weighted rules may match without satisfying
+	// every condition.
+	ConditionsMatched int
+	ConditionsTotal   int
+}
+
+// DetailedResult is the detailed outcome of matching a response
+type DetailedResult struct {
+	Results []MatchResult
+	// RedirectLoop is set when a URL repeats in the redirect chain of
+	// the response, a sign of a misconfigured WAF
+	RedirectLoop bool
+}
+
+// MatchDetailed returns the providers matching the response, sorted by
+// name, together with the evidence that triggered each match, and
+// whether the response was redirected in a loop. Variant providers are
+// always listed individually, with their parent. Provider and parent
+// names are aliased as configured with WithAliases.
+func (m *Matcher) MatchDetailed(resp Response) DetailedResult {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	in := m.newInput(resp, nil)
+	detailed := DetailedResult{Results: m.matchDetailed(in), RedirectLoop: hasRedirectLoop(in.Response)}
+	if len(m.aliases) == 0 {
+		return detailed
+	}
+	results := detailed.Results
+	for i := range results {
+		results[i].Provider = m.alias(results[i].Provider)
+		if results[i].Parent != "" {
+			results[i].Parent = m.alias(results[i].Parent)
+		}
+	}
+	slices.SortStableFunc(results, func(a, b MatchResult) int {
+		return strings.Compare(a.Provider, b.Provider)
+	})
+	return detailed
+}
+
+// matchDetailed returns the detailed results under the rule names. The
+// caller must hold the read lock.
+func (m *Matcher) matchDetailed(in *input) []MatchResult {
+	matches, _ := m.matchInput(in)
+	slices.Sort(matches)
+
+	results := make([]MatchResult, 0, len(matches))
+	for _, provider := range matches {
+		matched, total := m.countConditions(in, m.rules[provider])
+		results = append(results, MatchResult{
+			ID:          m.rules[provider].ID,
+			Provider:    provider,
+			Parent:      m.rules[provider].Parent,
+			BodyMatches: m.bodySpans(in, m.rules[provider]),
+
+			ConditionsMatched: matched,
+			ConditionsTotal:   total,
+		})
+	}
+	return results
+}
+
+// Evidence contains the response values that satisfied a provider rule
+type Evidence struct {
+	StatusCode int               `json:"status_code,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Trailers   map[string]string `json:"trailers,omitempty"`
+	Title      string            `json:"title,omitempty"`
+	Body       []Span            `json:"body,omitempty"`
+}
+
+// MatchMap returns the evidence for each provider matching the response,
+// keyed by provider name and ready to be marshaled as JSON
+func (m *Matcher) MatchMap(resp Response) map[string]Evidence {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	in := m.newInput(resp, nil)
+	results := m.matchDetailed(in)
+
+	evidence := make(map[string]Evidence, len(results))
+	for _, result := range results {
+		item := Evidence{Body: result.BodyMatches}
+		m.collectEvidence(in, m.rules[result.Provider], &item)
+		evidence[m.alias(result.Provider)] = item
+	}
+	return evidence
+}
+
+// collectEvidence adds the status, headers, trailers and title checked
+// by the rule and its first matching group to the evidence, as
+// countConditions walks them. The title is the candidate that matched.
+func (m *Matcher) collectEvidence(in *input, rule Rule, item *Evidence) {
+	if rule.HasStatus || rule.StatusReason != "" {
+		item.StatusCode = in.StatusCode
+	}
+
+	headers := slices.Concat(rule.HeadersPresent, rule.ReflectedHeaders)
+	for header := range rule.Headers {
+		headers = append(headers, header)
+	}
+	for header := range rule.HeaderCounts {
+		headers = append(headers, header)
+	}
+	if rule.AgeMin != 0 || rule.AgeMax != 0 {
+		headers = append(headers, "age")
+	}
+	if len(rule.Vary) > 0 {
+		headers = append(headers, "vary")
+	}
+	if len(rule.Cookies) > 0 {
+		headers = append(headers, "set-cookie")
+	}
+	if rule.LinkRel != "" || len(rule.LinkContains) > 0 {
+		headers = append(headers, "link")
+	}
+	item.Headers = addEvidence(item.Headers, in.Headers, headers...)
+	for trailer := range rule.Trailers {
+		item.Trailers = addEvidence(item.Trailers, in.Trailers, trailer)
+	}
+
+	if rule.hasTitleConditions() {
+		_, _, titles := in.fields(rule)
+		for i := range titles {
+			if m.matchTitle(in, rule, titles, i) {
+				item.Title = in.titles[i]
+				break
+			}
+		}
+	}
+
+	for _, group := range rule.Groups {
+		if m.matchRule(in, group) {
+			m.collectEvidence(in, group, item)
+			break
+		}
+	}
+}
+
+// addEvidence copies the listed headers present in the response to the
+// evidence, creating it when needed
+func addEvidence(evidence, headers map[string]string, names ...string) map[string]string {
+	for _, name := range names {
+		if value, ok := headers[name]; ok {
+			if evidence == nil {
+				evidence = make(map[string]string)
+			}
+			evidence[name] = value
+		}
+	}
+	return evidence
+}
+
+// bodySpans returns the body spans matched by the rule conditions,
+// including those of the first matching group. Spans of patterns found
+// in the lowercased body are mapped back to the original body. Regexes
+// are matched as matchRegex does, against the original body capped by
+// WithMaxRegexInput, whatever the case sensitivity of the rule.
+func (m *Matcher) bodySpans(resp *input, rule Rule) []Span {
+	_, body, _ := resp.fields(rule)
+
+	var spans []Span
+	for _, pattern := range rule.BodyContains {
+		idx := strings.Index(body, pattern)
+		if idx < 0 {
+			continue
+		}
+		start, end, ok := idx, idx+len(pattern), true
+		if body != resp.Body {
+			start, end, ok = originalSpan(resp.Body, start, end)
+		}
+		if ok {
+			spans = append(spans, Span{Start: start, End: end, Text: resp.Body[start:end]})
+		}
+	}
+	for _, re := range rule.BodyRegex {
+		if span, ok := regexSpan(re, m.regexInput(resp.Body)); ok {
+			spans = append(spans, span)
+		}
+	}
+	for _, group := range rule.Groups {
+		if m.matchRule(resp, group) {
+			spans = append(spans, m.bodySpans(resp, group)...)
+			break
+		}
+	}
+	return spans
+}
+
+// originalSpan maps offsets into strings.ToLower(body) back to offsets
+// into body, whose runes may lowercase to a different number of bytes.
+// ok is false when an offset falls inside a rune.
+func originalSpan(body string, lowerStart, lowerEnd int) (start, end int, ok bool) {
+	start, end = -1, -1
+	lowered := 0
+	for i, r := range body {
+		if lowered == lowerStart {
+			start = i
+		}
+		if lowered == lowerEnd {
+			end = i
+			break
+		}
+		if _, width := utf8.DecodeRuneInString(body[i:]); r == utf8.RuneError && width == 1 {
+			lowered += utf8.RuneLen(utf8.RuneError)
+		} else {
+			lowered += utf8.RuneLen(unicode.ToLower(r))
+		}
+	}
+	if lowered == lowerEnd && end < 0 {
+		end = len(body)
+	}
+	return start, end, start >= 0 && end >= 0
+}
+
+// regexSpan returns the span of the leftmost regex match in the body
+func regexSpan(re *regexp.Regexp, body string) (Span, bool) {
+	loc := re.FindStringIndex(body)
+	if loc == nil {
+		return Span{}, false
+	}
+	return Span{Start: loc[0], End: loc[1], Text: body[loc[0]:loc[1]]}, true
+}
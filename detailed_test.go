@@ -0,0 +1,209 @@
+package cleanhttp
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchDetailedBodySpans(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	body := `<p>The requested URL "[no URL]", is invalid.</p>`
+	results := matcher.MatchDetailed(Response{
+		StatusCode: 400,
+		Title:      "Invalid URL",
+		Body:       body,
+		Headers:    map[string]string{"Server": "AkamaiGHost"},
+	}).Results
+	require.Len(t, results, 1)
+	require.Equal(t, "akamai", results[0].Provider)
+	require.Equal(t, []Span{{Start: 3, End: 43, Text: `The requested URL "[no URL]", is invalid`}}, results[0].BodyMatches)
+
+	results = matcher.MatchDetailed(Response{
+		StatusCode: 503,
+		Headers:    map[string]string{"server": "cloudflare"},
+		Body:       "blocked: error code: 1020",
+	}).Results
+	require.Len(t, results, 1)
+	require.Equal(t, []Span{{Start: 9, End: 20, Text: "error code:"}}, results[0].BodyMatches)
+}
+
+func TestMatchDetailedCaseInsensitiveSpans(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("ci_block", RuleJSON{HTTPBody: []string{"Access Denied"}, CaseInsensitive: true}))
+
+	tests := []struct {
+		name string
+		body string
+		want []Span
+	}{
+		{name: "ascii", body: "<h1>ACCESS DENIED</h1>", want: []Span{{Start: 4, End: 17, Text: "ACCESS DENIED"}}},
+		{name: "shorter lowercase", body: "İSTANBUL: ACCESS DENIED", want: []Span{{Start: 11, End: 24, Text: "ACCESS DENIED"}}},
+		{name: "invalid utf-8", body: "\xff\xfe ACCESS DENIED", want: []Span{{Start: 3, End: 16, Text: "ACCESS DENIED"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := matcher.MatchDetailed(Response{Headers: map[string]string{"server": "blockguard"}, Body: tt.body}).Results
+			require.Len(t, results, 1)
+			require.Equal(t, tt.want, results[0].BodyMatches)
+		})
+	}
+}
+
+func TestMatchDetailedRegexSpans(t *testing.T) {
+	rules := []byte(`{"services": {
+		"repeated": {"http_body_regex": ["a+"]},
+		"ci_block": {"http_body_regex": ["(?i)access denied"], "http_body": ["access"], "case_insensitive": true}
+	}}`)
+
+	tests := []struct {
+		name  string
+		limit int
+		body  string
+		want  map[string][]Span
+	}{
+		{
+			name:  "capped",
+			limit: 8,
+			body:  strings.Repeat("a", 20),
+			want:  map[string][]Span{"repeated": {{Start: 0, End: 8, Text: "aaaaaaaa"}}},
+		},
+		{
+			name: "uncapped",
+			body: strings.Repeat("a", 20),
+			want: map[string][]Span{"repeated": {{Start: 0, End: 20, Text: strings.Repeat("a", 20)}}},
+		},
+		{
+			name: "case-insensitive",
+			body: "İ ACCESS DENIED",
+			want: map[string][]Span{"ci_block": {
+				{Start: 3, End: 9, Text: "ACCESS"},
+				{Start: 3, End: 16, Text: "ACCESS DENIED"},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher, err := newMatcher(rules, []Option{WithMaxRegexInput(tt.limit)})
+			require.NoError(t, err)
+			got := make(map[string][]Span)
+			for _, result := range matcher.MatchDetailed(Response{StatusCode: 200, Body: tt.body}).Results {
+				got[result.Provider] = result.BodyMatches
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMatchMapJSON(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	evidence := matcher.MatchMap(Response{
+		StatusCode: 503,
+		Headers:    map[string]string{"Server": "cloudflare", "Content-Type": "text/plain"},
+		Body:       "error code: 1020",
+	})
+
+	data, err := json.Marshal(evidence)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"cloudflare": {
+			"status_code": 503,
+			"headers": {"server": "cloudflare"},
+			"body": [{"start": 0, "end": 11, "text": "error code:"}]
+		}
+	}`, string(data))
+}
+
+func TestMatchMapEvidence(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"pairs": {"http_header": {"X-Guard": "on"}, "http_header_count": {"Via": 2}},
+		"trailed": {"http_trailer": {"X-Guard-Status": "blocked"}},
+		"titled": {"http_title": "Access Denied"},
+		"grouped": {"http_status_code": "403", "match_groups": [
+			{"http_header": {"X-Other": "x"}},
+			{"http_header": {"X-Group": "g"}}
+		]}
+	}}`), nil)
+	require.NoError(t, err)
+
+	evidence := matcher.MatchMap(Response{
+		StatusCode:  403,
+		HeaderPairs: [][2]string{{"X-Guard", "on"}, {"Via", "1.1 a"}, {"Via", "1.1 b"}, {"X-Group", "g"}},
+		Trailers:    map[string]string{"X-Guard-Status": "blocked"},
+		Title:       "Welcome",
+		Titles:      []string{"Access Denied"},
+	})
+	require.Equal(t, map[string]Evidence{
+		"pairs":   {Headers: map[string]string{"x-guard": "on", "via": "1.1 a, 1.1 b"}},
+		"trailed": {Trailers: map[string]string{"x-guard-status": "blocked"}},
+		"titled":  {Title: "Access Denied"},
+		"grouped": {StatusCode: 403, Headers: map[string]string{"x-group": "g"}},
+	}, evidence)
+}
+
+func TestMatchDetailedRuleID(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("sucuri", RuleJSON{ID: "sucuri-block-v2", HTTPHeaderPresent: []string{"X-Sucuri-ID"}}))
+
+	results := matcher.MatchDetailed(Response{
+		StatusCode: 503,
+		Headers:    map[string]string{"Server": "cloudflare", "X-Sucuri-ID": "11005"},
+		Body:       "error code: 1020",
+	}).Results
+	require.Len(t, results, 2)
+	require.Equal(t, "cloudflare", results[0].ID, "ID should default to the provider name")
+	require.Equal(t, "sucuri-block-v2", results[1].ID)
+	require.Equal(t, "sucuri", results[1].Provider)
+}
+
+func TestMatchDetailedConditions(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"full": {"http_status_code": "503", "http_header": {"Server": "cloudflare"}, "http_body": ["error code:"]},
+		"weighted": {
+			"http_header": {"Server": "cloudflare"}, "http_title": "Just a moment...", "http_body": ["error code:"],
+			"weights": {"header": 2, "title": 3, "body": 2}, "min_score": 4
+		}
+	}}`), nil)
+	require.NoError(t, err)
+
+	got := matcher.MatchDetailed(Response{StatusCode: 503, Headers: map[string]string{"Server": "cloudflare"}, Body: "error code: 1020"}).Results
+	require.Len(t, got, 2)
+	require.Equal(t, "full", got[0].Provider)
+	require.Equal(t, 3, got[0].ConditionsMatched)
+	require.Equal(t, 3, got[0].ConditionsTotal)
+	require.Equal(t, "weighted", got[1].Provider)
+	require.Equal(t, 2, got[1].ConditionsMatched)
+	require.Equal(t, 3, got[1].ConditionsTotal)
+}
+
+func TestMatchDetailedGroupConditions(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"grouped": {"match_groups": [
+			{"http_status_code": "403", "http_body": ["blocked"]},
+			{"http_header": {"Server": "cloudflare", "CF-Cache-Status": "DYNAMIC"}, "http_body": ["error code:"]}
+		]},
+		"headers": {"http_status_code": "503", "http_header": {"Server": "cloudflare"}, "http_header_present": ["CF-RAY"]}
+	}}`), nil)
+	require.NoError(t, err)
+
+	got := matcher.MatchDetailed(Response{
+		StatusCode: 503,
+		Headers:    map[string]string{"Server": "cloudflare", "CF-Cache-Status": "DYNAMIC", "CF-RAY": "8a1b2c3d4e5f6a7b-AMS"},
+		Body:       "error code: 1020",
+	}).Results
+	require.Len(t, got, 2)
+	require.Equal(t, "grouped", got[0].Provider)
+	require.Equal(t, 3, got[0].ConditionsMatched, "conditions of the matched group")
+	require.Equal(t, 3, got[0].ConditionsTotal)
+	require.Equal(t, "headers", got[1].Provider)
+	require.Equal(t, 3, got[1].ConditionsMatched, "header conditions count separately")
+	require.Equal(t, 3, got[1].ConditionsTotal)
+}
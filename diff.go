@@ -0,0 +1,42 @@
+package cleanhttp
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// DiffRules compares two JSON rulesets, returning the sorted providers
+// only present in new, only present in old, and present in both with
+// different conditions. Rules are compared in their compiled form, so
+// formatting and key order in the JSON are not reported as changes.
+func DiffRules(old, new []byte) (added, removed, changed []string, err error) {
+	oldRules, err := newMatcher(old, nil)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("loading old rules: %w", err)
+	}
+	newRules, err := newMatcher(new, nil)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("loading new rules: %w", err)
+	}
+
+	for provider, rule := range newRules.rules {
+		oldRule, ok := oldRules.rules[provider]
+		switch {
+		case !ok:
+			added = append(added, provider)
+		case !reflect.DeepEqual(oldRule, rule):
+			changed = append(changed, provider)
+		}
+	}
+	for provider := range oldRules.rules {
+		if _, ok := newRules.rules[provider]; !ok {
+			removed = append(removed, provider)
+		}
+	}
+
+	slices.Sort(added)
+	slices.Sort(removed)
+	slices.Sort(changed)
+	return added, removed, changed, nil
+}
@@ -0,0 +1,29 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffRules(t *testing.T) {
+	old := []byte(`{"services": {
+		"cloudflare": {"http_status_code": "500-599", "http_header": {"Server": "cloudflare"}},
+		"akamai": {"http_header": {"Server": "AkamaiGHost"}},
+		"legacy": {"http_body": ["legacy"]}
+	}}`)
+	new := []byte(`{"services": {
+		"akamai": {"http_header": {"server": "AkamaiGHost"}},
+		"cloudflare": {"http_header": {"Server": "cloudflare"}, "http_status_code": "400-599"},
+		"cloudfront": {"http_header": {"Server": "CloudFront"}}
+	}}`)
+
+	added, removed, changed, err := DiffRules(old, new)
+	require.NoError(t, err)
+	require.Equal(t, []string{"cloudfront"}, added)
+	require.Equal(t, []string{"legacy"}, removed)
+	require.Equal(t, []string{"cloudflare"}, changed)
+
+	_, _, _, err = DiffRules(old, []byte(`{"services": {"bad": {"http_body_regex": ["("]}}}`))
+	require.Error(t, err)
+}
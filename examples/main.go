@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"regexp"
 	"strings"
 
 	"github.com/projectdiscovery/cleanhttp"
@@ -40,12 +39,8 @@ func main() {
 			headers[k] = strings.Join(v, ", ")
 		}
 
-		// Extract title using regex for demo
-		titleRegex := regexp.MustCompile(`<title[^>]*>([^<]+)</title>`)
-		title := ""
-		if matches := titleRegex.FindStringSubmatch(string(body)); len(matches) > 1 {
-			title = matches[1]
-		}
+		// ExtractTitle reuses a regex compiled once for the package
+		title := cleanhttp.ExtractTitle(string(body))
 
 		cleanResp := cleanhttp.Response{
 			StatusCode: resp.StatusCode,
@@ -0,0 +1,208 @@
+package cleanhttp
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Results of a rule condition
+const (
+	ResultOK      = "ok"
+	ResultFailed  = "failed"
+	ResultMissing = "missing"
+	ResultUnknown = "unknown"
+	ResultSkipped = "skipped"
+)
+
+// Condition is the outcome of a single rule condition for a response
+type Condition struct {
+	// Signal is the signal the condition belongs to, empty for the
+	// validity window, on_404 and min_score conditions
+	Signal string `json:"signal,omitempty"`
+	// Description describes the condition, e.g. "status 503 in range
+	// 500-599"
+	Description string `json:"description"`
+	// Result is ResultOK when the condition holds. Otherwise it is
+	// ResultMissing when the value checked is absent, ResultUnknown when
+	// it cannot be determined, ResultSkipped when the condition was not
+	// evaluated, or ResultFailed.
+	Result string `json:"result"`
+}
+
+// Met reports whether the condition holds
+func (c Condition) Met() bool {
+	return c.Result == ResultOK
+}
+
+// String returns the condition in the format of Explain reasons
+func (c Condition) String() string {
+	return c.Description + ": " + c.Result
+}
+
+// Explain evaluates the rule of a single provider against the response
+// and returns whether it matched, along with a human-readable reason
+// for every condition of the rule, such as
+// "status 503 in range 500-599: ok" or
+// "header server contains cloudflare: missing".
+func (m *Matcher) Explain(resp Response, provider string) (matched bool, reasons []string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rule, ok := m.rules[provider]
+	if !ok {
+		return false, []string{fmt.Sprintf("unknown provider %q", provider)}
+	}
+	matched, conditions := m.explainProvider(m.newInput(resp, nil), rule, m.clock())
+	for _, condition := range conditions {
+		reasons = append(reasons, condition.String())
+	}
+	return matched, reasons
+}
+
+// explainProvider evaluates a rule against the input, returning whether
+// it matched and the outcome of every condition. The caller must hold
+// the read lock.
+func (m *Matcher) explainProvider(in *input, rule Rule, now time.Time) (matched bool, conditions []Condition) {
+	add := func(ok bool, description string) {
+		result := ResultOK
+		if !ok {
+			result = ResultFailed
+		}
+		conditions = append(conditions, Condition{Description: description, Result: result})
+	}
+	record := func(condition Condition) {
+		conditions = append(conditions, condition)
+	}
+
+	if !rule.ValidFrom.IsZero() || !rule.ValidUntil.IsZero() {
+		add(rule.activeAt(now), "rule valid at "+now.Format("2006-01-02T15:04:05Z07:00"))
+	}
+	var score float64
+	for _, signal := range signals {
+		if checked, ok := m.evalSignal(in, rule, signal, record); checked && ok {
+			score += rule.Weights[signal]
+		}
+	}
+	if rule.On404 != nil {
+		add(in.errorPage != nil && m.matchRule(in.errorPage, *rule.On404), "error page matches on_404 conditions")
+	}
+	if rule.MinScore > 0 {
+		add(score >= rule.MinScore, fmt.Sprintf("score %g reaches %g", score, rule.MinScore))
+	}
+	return rule.activeAt(now) && m.matchRule(in, rule), conditions
+}
+
+// ANSI escape sequences used by FormatExplanationColor
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+)
+
+// FormatExplanation returns a human-readable report of the providers
+// matching the response and of the near misses, the providers meeting
+// some but not all of their conditions, with the result of each
+// condition:
+//
+//	MATCH cloudflare (3/3 conditions)
+//	  [ok]      status 503 in range 500-599
+//	  ...
+//	NEAR MISS akamai (3/4 conditions)
+//	  [failed]  title is "Invalid URL"
+//
+// Matches are listed first, then near misses by decreasing number of
+// conditions met. The report is plain text, see FormatExplanationColor
+// for terminals.
+func (m *Matcher) FormatExplanation(resp Response) string {
+	return m.formatExplanation(resp, false)
+}
+
+// FormatExplanationColor is FormatExplanation with ANSI colors, for
+// printing to terminals
+func (m *Matcher) FormatExplanationColor(resp Response) string {
+	return m.formatExplanation(resp, true)
+}
+
+// formatExplanation writes the report of FormatExplanation
+func (m *Matcher) formatExplanation(resp Response, color bool) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	type entry struct {
+		provider   string
+		matched    bool
+		met        int
+		conditions []Condition
+	}
+	in := m.newInput(resp, nil)
+	now := m.clock()
+	var entries []entry
+	for _, provider := range sortedKeys(m.rules) {
+		matched, conditions := m.explainProvider(in, m.rules[provider], now)
+		met := countMet(conditions)
+		if matched || met > 0 {
+			entries = append(entries, entry{provider: provider, matched: matched, met: met, conditions: conditions})
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		if a.matched != b.matched {
+			if a.matched {
+				return -1
+			}
+			return 1
+		}
+		return b.met - a.met
+	})
+
+	paint := func(code, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + ansiReset
+	}
+	var b strings.Builder
+	if len(entries) == 0 {
+		b.WriteString("no matches or near misses\n")
+	}
+	for _, entry := range entries {
+		heading := paint(ansiBold+ansiYellow, "NEAR MISS")
+		if entry.matched {
+			heading = paint(ansiBold+ansiGreen, "MATCH")
+		}
+		fmt.Fprintf(&b, "%s %s (%d/%d conditions)\n", heading, paint(ansiBold, entry.provider), entry.met, len(entry.conditions))
+		for _, condition := range entry.conditions {
+			code := ansiRed
+			if condition.Met() {
+				code = ansiGreen
+			}
+			label := fmt.Sprintf("%-9s", "["+condition.Result+"]")
+			fmt.Fprintf(&b, "  %s %s\n", paint(code, label), condition.Description)
+		}
+	}
+	return b.String()
+}
+
+// countMet returns how many of the conditions hold
+func countMet(conditions []Condition) int {
+	met := 0
+	for _, condition := range conditions {
+		if condition.Met() {
+			met++
+		}
+	}
+	return met
+}
+
+// sortedKeys returns the keys of the map in order
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
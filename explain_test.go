@@ -0,0 +1,67 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	resp := Response{
+		StatusCode: 400,
+		Headers:    map[string]string{"Server": "AkamaiGHost"},
+		Title:      "Bad Request",
+		Body:       "The requested URL \"[no URL]\", is invalid.",
+	}
+	matched, reasons := matcher.Explain(resp, "akamai")
+	require.False(t, matched)
+	require.Equal(t, []string{
+		"status 400 is 400: ok",
+		"header server contains AkamaiGHost: ok",
+		`body matches "The requested URL .* is invalid": ok`,
+		`title is "Invalid URL": failed`,
+	}, reasons)
+
+	resp.Headers = nil
+	resp.Title = "Invalid URL"
+	_, reasons = matcher.Explain(resp, "akamai")
+	require.Contains(t, reasons, "header server contains AkamaiGHost: missing")
+
+	resp.Headers = map[string]string{"Server": "AkamaiGHost"}
+	matched, _ = matcher.Explain(resp, "akamai")
+	require.True(t, matched)
+
+	matched, reasons = matcher.Explain(resp, "missing")
+	require.False(t, matched)
+	require.Equal(t, []string{`unknown provider "missing"`}, reasons)
+}
+
+func TestFormatExplanation(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	resp := Response{
+		StatusCode: 400,
+		Headers:    map[string]string{"Server": "AkamaiGHost"},
+		Title:      "Bad Request",
+		Body:       "The requested URL \"[no URL]\", is invalid.",
+	}
+	report := matcher.FormatExplanation(resp)
+	require.Contains(t, report, "NEAR MISS akamai (3/4 conditions)\n")
+	require.Contains(t, report, "  [ok]      header server contains AkamaiGHost\n")
+	require.Contains(t, report, "  [failed]  title is \"Invalid URL\"\n")
+	require.NotContains(t, report, "MATCH akamai")
+	require.NotContains(t, report, "\x1b[")
+
+	colored := matcher.FormatExplanationColor(resp)
+	require.Contains(t, colored, "\x1b[32m[ok]     \x1b[0m header server contains AkamaiGHost")
+	require.Contains(t, colored, "\x1b[31m[failed] \x1b[0m title is \"Invalid URL\"")
+
+	resp.Title = "Invalid URL"
+	require.Contains(t, matcher.FormatExplanation(resp), "MATCH akamai (4/4 conditions)\n")
+
+	require.Equal(t, "no matches or near misses\n", matcher.FormatExplanation(Response{StatusCode: 299}))
+}
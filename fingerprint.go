@@ -0,0 +1,92 @@
+package cleanhttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxFingerprintTokens bounds the body tokens included in a fingerprint
+const maxFingerprintTokens = 32
+
+// volatileHeaders change between otherwise identical responses and are
+// left out of fingerprints
+var volatileHeaders = map[string]struct{}{
+	"age":            {},
+	"cf-ray":         {},
+	"content-length": {},
+	"date":           {},
+	"etag":           {},
+	"expires":        {},
+	"last-modified":  {},
+	"report-to":      {},
+	"set-cookie":     {},
+	"x-amz-cf-id":    {},
+	"x-request-id":   {},
+}
+
+// Fingerprint returns a stable hash of the structure of a response, so
+// that similar pages, such as two block pages of the same vendor with
+// different request IDs, can be grouped together. The hash covers:
+//
+//   - the status class (e.g. 4xx)
+//   - the sorted names of the headers, without volatile ones such as
+//     Date, Content-Length or Set-Cookie, and the Server value
+//   - the lowercased title with digits replaced by 0
+//   - the body length rounded down to a power of two
+//   - up to 32 sorted distinct body words of four or more letters
+func Fingerprint(resp Response) string {
+	headers := NormalizeHeaders(resp.Headers)
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		if _, volatile := volatileHeaders[name]; !volatile {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(resp.StatusCode/100) + "xx\n")
+	b.WriteString(strings.Join(names, ",") + "\n")
+	b.WriteString(strings.ToLower(headers["server"]) + "\n")
+	b.WriteString(normalizeDigits(strings.ToLower(strings.TrimSpace(resp.Title))) + "\n")
+	b.WriteString(strconv.Itoa(bits.Len(uint(len(resp.Body)))) + "\n")
+	b.WriteString(strings.Join(bodyTokens(resp.Body), " "))
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// normalizeDigits replaces every digit with 0
+func normalizeDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return '0'
+		}
+		return r
+	}, s)
+}
+
+// bodyTokens returns the first sorted distinct lowercased words of the
+// body made only of four or more letters
+func bodyTokens(body string) []string {
+	words := strings.FieldsFunc(strings.ToLower(body), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var tokens []string
+	for _, word := range words {
+		if len(word) >= 4 && strings.IndexFunc(word, unicode.IsDigit) < 0 {
+			tokens = append(tokens, word)
+		}
+	}
+	slices.Sort(tokens)
+	tokens = slices.Compact(tokens)
+	if len(tokens) > maxFingerprintTokens {
+		tokens = tokens[:maxFingerprintTokens]
+	}
+	return tokens
+}
@@ -0,0 +1,36 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	blockPage := func(ray, date string) Response {
+		return Response{
+			StatusCode: 403,
+			Headers: map[string]string{
+				"Server":       "cloudflare",
+				"CF-RAY":       ray,
+				"Date":         date,
+				"Content-Type": "text/html",
+			},
+			Title: "Attention Required! | Cloudflare",
+			Body:  "<html>Sorry, you have been blocked. Ray ID: " + ray + "</html>",
+		}
+	}
+
+	first := Fingerprint(blockPage("8c1f2a3b4c5d6e7f", "Mon, 01 Jan 2026 00:00:00 GMT"))
+	second := Fingerprint(blockPage("9a8b7c6d5e4f3a2b", "Tue, 02 Jan 2026 10:11:12 GMT"))
+	require.Equal(t, first, second)
+	require.Len(t, first, 16)
+
+	other := Fingerprint(Response{
+		StatusCode: 403,
+		Headers:    map[string]string{"Server": "AkamaiGHost", "Content-Type": "text/html"},
+		Title:      "Access Denied",
+		Body:       "<html>You don't have permission to access this server.</html>",
+	})
+	require.NotEqual(t, first, other)
+}
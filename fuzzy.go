@@ -0,0 +1,51 @@
+package cleanhttp
+
+// maxFuzzyTitleLength bounds the number of runes compared when fuzzy
+// matching titles, keeping the edit distance computation cheap
+const maxFuzzyTitleLength = 512
+
+// FuzzyTitle matches titles within an edit distance of a reference title
+type FuzzyTitle struct {
+	Title       string `json:"title"`
+	MaxDistance int    `json:"max_distance"`
+}
+
+// match reports whether the title is within the maximum edit distance
+func (f *FuzzyTitle) match(title string) bool {
+	want, got := []rune(f.Title), []rune(title)
+	if len(want) > maxFuzzyTitleLength || len(got) > maxFuzzyTitleLength {
+		return false
+	}
+	if abs(len(want)-len(got)) > f.MaxDistance {
+		return false
+	}
+	return levenshtein(want, got) <= f.MaxDistance
+}
+
+// levenshtein returns the edit distance between two rune slices
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,37 @@
+package cleanhttp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuzzyTitle(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	err = matcher.AddRule("cloudflare_attention", RuleJSON{
+		HTTPTitleFuzzy: &FuzzyTitle{Title: "Attention Required! | Cloudflare", MaxDistance: 2},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		title string
+		want  []string
+	}{
+		{title: "Attention Required! | Cloudflare", want: []string{"cloudflare_attention"}},
+		{title: "Attention Required! - Cloudflare", want: []string{"cloudflare_attention"}},
+		{title: "Attention Required | Cloudflare", want: []string{"cloudflare_attention"}},
+		{title: "Just a moment...", want: nil},
+		{title: strings.Repeat("Attention Required! | Cloudflare", 100), want: nil},
+	}
+	for _, tt := range tests {
+		require.ElementsMatch(t, tt.want, matcher.Match(Response{Title: tt.title}), tt.title)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	require.Equal(t, 0, levenshtein([]rune("same"), []rune("same")))
+	require.Equal(t, 3, levenshtein([]rune("kitten"), []rune("sitting")))
+	require.Equal(t, 4, levenshtein([]rune(""), []rune("four")))
+}
@@ -0,0 +1,34 @@
+package cleanhttp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// MatchGRPC reports whether the response comes from a gRPC endpoint,
+// recognized by its application/grpc content type (including the
+// +proto, +json and -web variants), and returns the code of its
+// grpc-status trailer. Trailers-only responses, which carry the status
+// in the headers, are supported. The status is -1 when missing or
+// invalid.
+func MatchGRPC(resp Response) (bool, int) {
+	headers := NormalizeHeaders(resp.Headers)
+	contentType := mediaType(headers["content-type"])
+	if contentType != "application/grpc" && !strings.HasPrefix(contentType, "application/grpc+") &&
+		!strings.HasPrefix(contentType, "application/grpc-web") {
+		return false, -1
+	}
+
+	value, ok := NormalizeHeaders(resp.Trailers)["grpc-status"]
+	if !ok {
+		value, ok = headers["grpc-status"]
+	}
+	if !ok {
+		return true, -1
+	}
+	status, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || status < 0 {
+		return true, -1
+	}
+	return true, status
+}
@@ -0,0 +1,73 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchGRPC(t *testing.T) {
+	tests := []struct {
+		name     string
+		response Response
+		grpc     bool
+		status   int
+	}{
+		{
+			name: "status trailer",
+			response: Response{
+				StatusCode: 200,
+				Headers:    map[string]string{"Content-Type": "application/grpc"},
+				Trailers:   map[string]string{"Grpc-Status": "7", "Grpc-Message": "permission denied"},
+			},
+			grpc:   true,
+			status: 7,
+		},
+		{
+			name: "trailers only",
+			response: Response{
+				StatusCode: 200,
+				Headers:    map[string]string{"Content-Type": "application/grpc+proto", "grpc-status": "12"},
+			},
+			grpc:   true,
+			status: 12,
+		},
+		{
+			name: "grpc web without status",
+			response: Response{
+				StatusCode: 200,
+				Headers:    map[string]string{"Content-Type": "application/grpc-web+proto"},
+			},
+			grpc:   true,
+			status: -1,
+		},
+		{
+			name: "json api",
+			response: Response{
+				StatusCode: 200,
+				Headers:    map[string]string{"Content-Type": "application/json"},
+				Trailers:   map[string]string{"grpc-status": "0"},
+			},
+			grpc:   false,
+			status: -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grpc, status := MatchGRPC(tt.response)
+			require.Equal(t, tt.grpc, grpc)
+			require.Equal(t, tt.status, status)
+		})
+	}
+
+	// Rules recognize gRPC endpoints with the existing keys
+	matcher, err := newMatcher([]byte(`{"services": {
+		"grpc_unauthenticated": {"http_content_type": ["application/grpc"], "http_trailer": {"grpc-status": "16"}}
+	}}`), nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"grpc_unauthenticated"}, matcher.Match(Response{
+		StatusCode: 200,
+		Headers:    map[string]string{"Content-Type": "application/grpc"},
+		Trailers:   map[string]string{"Grpc-Status": "16"},
+	}))
+}
@@ -0,0 +1,54 @@
+package cleanhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// HeaderPatterns lists alternative substrings for a header, any of
+// which may match. In JSON it is either a single string or an array.
+type HeaderPatterns []string
+
+// UnmarshalJSON decodes header patterns from a string or a non-empty
+// array
+func (h *HeaderPatterns) UnmarshalJSON(data []byte) error {
+	var pattern string
+	if err := json.Unmarshal(data, &pattern); err == nil && string(data) != "null" {
+		*h = HeaderPatterns{pattern}
+		return nil
+	}
+
+	var patterns []string
+	if err := json.Unmarshal(data, &patterns); err != nil {
+		return fmt.Errorf("header value must be a string or an array of strings: %w", err)
+	}
+	if len(patterns) == 0 {
+		return errors.New("header value must list at least one pattern")
+	}
+	*h = patterns
+	return nil
+}
+
+// MarshalJSON encodes a single pattern as a plain string
+func (h HeaderPatterns) MarshalJSON() ([]byte, error) {
+	if len(h) == 1 {
+		return json.Marshal(h[0])
+	}
+	return json.Marshal([]string(h))
+}
+
+// headerTokens splits comma separated header values into lowercased
+// tokens, skipping empty ones
+func headerTokens(values []string) []string {
+	var tokens []string
+	for _, value := range values {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.ToLower(strings.TrimSpace(token)); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,129 @@
+package cleanhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderPatternAlternatives(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRules([]byte(`{"services": {"google_frontend": {
+		"http_header": {"Server": ["cloudflare", "sffe", "ESF"]}
+	}}}`)))
+
+	for _, server := range []string{"cloudflare", "sffe", "ESF"} {
+		got := matcher.MatchDetailed(Response{StatusCode: 200, Headers: map[string]string{"Server": server}}).Results
+		require.Len(t, got, 1, server)
+		require.Equal(t, "google_frontend", got[0].Provider)
+	}
+	require.Empty(t, matcher.MatchDetailed(Response{StatusCode: 200, Headers: map[string]string{"Server": "nginx"}}).Results)
+}
+
+func TestHeaderPatternsJSON(t *testing.T) {
+	var headers map[string]HeaderPatterns
+	require.NoError(t, json.Unmarshal([]byte(`{"Server": "cloudflare", "Via": ["varnish", "squid"]}`), &headers))
+	require.Equal(t, map[string]HeaderPatterns{"Server": {"cloudflare"}, "Via": {"varnish", "squid"}}, headers)
+
+	data, err := json.Marshal(headers)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"Server": "cloudflare", "Via": ["varnish", "squid"]}`, string(data))
+
+	require.Error(t, json.Unmarshal([]byte(`{"Server": 1}`), &headers))
+	require.Error(t, json.Unmarshal([]byte(`{"Server": []}`), &headers), "empty alternatives never match")
+	require.Error(t, json.Unmarshal([]byte(`{"Server": null}`), &headers))
+
+	_, err = compileRule(RuleJSON{HTTPHeader: map[string]HeaderPatterns{"Server": {}}})
+	require.Error(t, err)
+}
+
+func TestHTTPVary(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("vary_cdn", RuleJSON{HTTPVary: []string{"User-Agent", "accept-encoding"}}))
+
+	tests := []struct {
+		vary string
+		want []string
+	}{
+		{vary: "Accept-Encoding, User-Agent", want: []string{"vary_cdn"}},
+		{vary: "Origin,user-agent ,Accept-Encoding", want: []string{"vary_cdn"}},
+		{vary: "Accept-Encoding", want: nil},
+		{vary: "Accept-Encoding-Extra, User-Agent", want: nil},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, matcher.Match(Response{Headers: map[string]string{"Vary": tt.vary}}), tt.vary)
+	}
+	require.Empty(t, matcher.Match(Response{}))
+}
+
+func TestHeaderCount(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"proxied": {"http_header_count": {"Via": 2}}
+	}}`), nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		pairs [][2]string
+		want  []string
+	}{
+		{
+			name:  "two hops",
+			pairs: [][2]string{{"Via", "1.1 varnish"}, {"Server", "nginx"}, {"via", "1.1 abc.cloudfront.net (CloudFront)"}},
+			want:  []string{"proxied"},
+		},
+		{
+			name:  "single hop",
+			pairs: [][2]string{{"Via", "1.1 varnish"}},
+			want:  nil,
+		},
+		{
+			name:  "no via",
+			pairs: [][2]string{{"Server", "nginx"}},
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, matcher.Match(Response{StatusCode: 200, HeaderPairs: tt.pairs}))
+		})
+	}
+
+	header := http.Header{"Via": {"1.1 varnish", "1.1 squid"}}
+	require.Equal(t, []string{"proxied"}, matcher.MatchHeader(200, header, "", ""))
+
+	_, err = newMatcher([]byte(`{"services": {"bad": {"http_header_count": {"Via": 0}}}}`), nil)
+	require.ErrorContains(t, err, "invalid count 0 for header Via")
+}
+
+func TestAge(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"cached": {"http_header": {"X-Cache": "HIT"}, "http_age_min": 60, "http_age_max": 86400}
+	}}`), nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		age  string
+		want []string
+	}{
+		{name: "within window", age: "3600", want: []string{"cached"}},
+		{name: "too fresh", age: "5", want: nil},
+		{name: "too old", age: "90000", want: nil},
+		{name: "not a number", age: "soon", want: nil},
+		{name: "missing", age: "", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{"X-Cache": "HIT from cloudfront"}
+			if tt.age != "" {
+				headers["Age"] = tt.age
+			}
+			require.Equal(t, tt.want, matcher.Match(Response{StatusCode: 200, Headers: headers}))
+		})
+	}
+}
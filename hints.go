@@ -0,0 +1,111 @@
+package cleanhttp
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Hint is a condition of a provider rule satisfied by a response the
+// provider did not fully match
+type Hint struct {
+	Provider string `json:"provider"`
+	Signal   string `json:"signal"`
+	Detail   string `json:"detail"`
+}
+
+// Hints reports the header, cookie, body and title conditions of rules
+// that the response satisfies although their provider did not match,
+// such as a CF-RAY header without a full Cloudflare match. Hints are
+// attributed to the parent of variant rules and sorted by provider.
+func (m *Matcher) Hints(resp Response) []Hint {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	in := m.newInput(resp, nil)
+	matches, _ := m.matchInput(in)
+	matched := make(map[string]struct{}, len(matches))
+	for _, provider := range matches {
+		matched[provider] = struct{}{}
+		if parent := m.rules[provider].Parent; parent != "" {
+			matched[parent] = struct{}{}
+		}
+	}
+
+	providers := make([]string, 0, len(m.rules))
+	for provider := range m.rules {
+		providers = append(providers, provider)
+	}
+	slices.Sort(providers)
+
+	var hints []Hint
+	for _, provider := range providers {
+		rule := m.rules[provider]
+		if rule.Parent != "" {
+			provider = rule.Parent
+		}
+		if _, ok := matched[provider]; ok || !m.providerAllowed(provider) {
+			continue
+		}
+		for _, hint := range ruleHints(in, rule, provider) {
+			if !slices.Contains(hints, hint) {
+				hints = append(hints, hint)
+			}
+		}
+	}
+	slices.SortStableFunc(hints, func(a, b Hint) int {
+		return strings.Compare(a.Provider, b.Provider)
+	})
+	return hints
+}
+
+// ruleHints returns the individual conditions of the rule and its match
+// groups satisfied by the response
+func ruleHints(in *input, rule Rule, provider string) []Hint {
+	headers, body, titles := in.fields(rule)
+
+	var hints []Hint
+	hint := func(signal, format string, args ...any) {
+		hints = append(hints, Hint{Provider: provider, Signal: signal, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	for header, patterns := range rule.Headers {
+		for _, pattern := range patterns {
+			if matchHeaders(headers, map[string][]string{header: {pattern}}) {
+				hint(SignalHeader, "%s contains %q", header, pattern)
+			}
+		}
+	}
+	for _, header := range rule.HeadersPresent {
+		if _, ok := in.Headers[header]; ok {
+			hint(SignalHeader, "%s present", header)
+		}
+	}
+	names := cookieNames(in.values["set-cookie"])
+	for _, cookie := range rule.Cookies {
+		if matchCookies(names, []string{cookie}) {
+			hint(SignalHeader, "cookie %s set", cookie)
+		}
+	}
+	for _, pattern := range rule.BodyContains {
+		if strings.Contains(body, pattern) {
+			hint(SignalBody, "body contains %q", pattern)
+		}
+	}
+	for _, line := range rule.BodyLines {
+		if containsLine(body, line) {
+			hint(SignalBody, "body has line %q", line)
+		}
+	}
+	if rule.TitleExact != "" && slices.Contains(titles, rule.TitleExact) {
+		hint(SignalTitle, "title is %q", rule.TitleExact)
+	}
+
+	for _, group := range rule.Groups {
+		hints = append(hints, ruleHints(in, group, provider)...)
+	}
+	slices.SortFunc(hints, func(a, b Hint) int {
+		return strings.Compare(a.Detail, b.Detail)
+	})
+	return hints
+}
@@ -0,0 +1,31 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHints(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("cloudflare_challenge", RuleJSON{
+		Parent:            "cloudflare",
+		HTTPStatusCode:    "403",
+		HTTPHeader:        map[string]HeaderPatterns{"Server": {"cloudflare"}},
+		HTTPHeaderPresent: []string{"CF-RAY"},
+		HTTPTitle:         "Just a moment...",
+	}))
+
+	resp := Response{StatusCode: 200, Headers: map[string]string{"CF-RAY": "8c1f2a3b4c5d6e7f-FRA", "Server": "nginx"}}
+	require.Empty(t, matcher.Match(resp))
+	require.Equal(t, []Hint{{Provider: "cloudflare", Signal: SignalHeader, Detail: "cf-ray present"}}, matcher.Hints(resp))
+
+	matched := Response{StatusCode: 503, Headers: map[string]string{"CF-RAY": "8c1f2a3b4c5d6e7f-FRA", "Server": "cloudflare"}, Body: "error code: 1020"}
+	require.Equal(t, []string{"cloudflare"}, matcher.Match(matched))
+	for _, hint := range matcher.Hints(matched) {
+		require.NotEqual(t, "cloudflare", hint.Provider, "matched providers get no hints")
+	}
+
+	require.Empty(t, matcher.Hints(Response{StatusCode: 200, Headers: map[string]string{"Server": "nginx"}}))
+}
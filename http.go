@@ -0,0 +1,179 @@
+package cleanhttp
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// titleRegex is compiled once and shared by every ExtractTitle call
+var titleRegex = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// TitleOnlyReadLimit caps the body bytes read by MatchHTTPResponse in
+// title-only mode when no end of head is found
+const TitleOnlyReadLimit = 64 << 10
+
+// ExtractTitle returns the trimmed contents of the first title tag in
+// the body, or an empty string if there is none. It is safe for
+// concurrent use and does not compile a regex per call, so callers
+// should prefer it over their own title regex.
+func ExtractTitle(body string) string {
+	matches := titleRegex.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		return ""
+	}
+	return strings.TrimSpace(html.UnescapeString(matches[1]))
+}
+
+// ParseResponse builds a Response from an HTTP response. The body is
+// read fully and replaced with an in-memory copy so it can still be
+// consumed by the caller.
+func ParseResponse(resp *http.Response) (Response, error) {
+	return parseResponse(resp, ExtractTitle)
+}
+
+// parseResponse is ParseResponse with the given title extractor
+func parseResponse(resp *http.Response, extractTitle func(body string) string) (Response, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return Response{}, fmt.Errorf("reading response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	parsed := responseMeta(resp)
+	parsed.Body = string(body)
+	parsed.Title = extractTitle(parsed.Body)
+	// Trailers are only available once the body has been read
+	parsed.Trailers = flattenHeader(resp.Trailer)
+	return parsed, nil
+}
+
+// responseMeta builds a Response from the status, headers and request
+// of an HTTP response, without reading its body
+func responseMeta(resp *http.Response) Response {
+	parsed := Response{
+		StatusCode:   resp.StatusCode,
+		StatusReason: statusReason(resp),
+		Headers:      flattenHeader(resp.Header),
+		HeaderPairs:  headerPairs(resp.Header),
+	}
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		leaf := resp.TLS.PeerCertificates[0]
+		parsed.TLSCommonName = leaf.Subject.CommonName
+		parsed.TLSSANs = leaf.DNSNames
+	}
+	if resp.Request != nil {
+		parsed.Method = resp.Request.Method
+		parsed.RequestHeaders = flattenHeader(resp.Request.Header)
+		if resp.Request.URL != nil {
+			parsed.RequestURL = resp.Request.URL.String()
+		}
+	}
+	return parsed
+}
+
+// readHead reads the body up to the end of the HTML head or limit
+// bytes, and puts the bytes read back in front of the remaining body
+func readHead(resp *http.Response, limit int) ([]byte, error) {
+	var head []byte
+	chunk := make([]byte, 4096)
+	for len(head) < limit {
+		n, err := resp.Body.Read(chunk[:min(len(chunk), limit-len(head))])
+		head = append(head, chunk[:n]...)
+		if err == io.EOF || bytes.Contains(bytes.ToLower(head), []byte("</head>")) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading response body: %w", err)
+		}
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+	return head, nil
+}
+
+// statusReason returns the reason phrase of the status line, which
+// http.Response keeps prefixed by the status code
+func statusReason(resp *http.Response) string {
+	return strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode)))
+}
+
+// MatchHTTPResponse returns the names of WAF/CDN providers that match
+// the HTTP response
+func (m *Matcher) MatchHTTPResponse(resp *http.Response) ([]string, error) {
+	if m.titleOnly {
+		return m.matchTitleOnly(resp)
+	}
+	parsed, err := parseResponse(resp, m.extractTitle)
+	if err != nil {
+		return nil, err
+	}
+	return m.Match(parsed), nil
+}
+
+// matchTitleOnly matches the response with the title found in the
+// start of the body, failing rules with body conditions
+func (m *Matcher) matchTitleOnly(resp *http.Response) ([]string, error) {
+	head, err := readHead(resp, TitleOnlyReadLimit)
+	if err != nil {
+		return nil, err
+	}
+	parsed := responseMeta(resp)
+	parsed.Title = m.extractTitle(string(head))
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	in := m.newInput(parsed, nil)
+	in.skipBody = true
+	matches, _ := m.matchInput(in)
+	return m.resultNames(matches), nil
+}
+
+// flattenHeader joins multiple header values into a single value
+func flattenHeader(header http.Header) map[string]string {
+	flattened := make(map[string]string, len(header))
+	for k, v := range header {
+		flattened[k] = strings.Join(v, ", ")
+	}
+	return flattened
+}
+
+// headerPairs lists every header value as a name/value pair, so that
+// repeated headers survive flattening. Names are sorted as http.Header
+// does not keep their order.
+func headerPairs(header http.Header) [][2]string {
+	var pairs [][2]string
+	for _, name := range sortedKeys(header) {
+		for _, value := range header[name] {
+			pairs = append(pairs, [2]string{name, value})
+		}
+	}
+	return pairs
+}
+
+// MatchHeader returns the names of WAF/CDN providers that match a
+// response whose headers are given as an http.Header. Header patterns
+// match if any of the values of a header contains them.
+func (m *Matcher) MatchHeader(status int, h http.Header, body, title string) []string {
+	resp := Response{
+		StatusCode: status,
+		Headers:    flattenHeader(h),
+		Body:       body,
+		Title:      title,
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matches, _ := m.matchInput(m.newInput(resp, h))
+	return m.resultNames(matches)
+}
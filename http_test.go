@@ -0,0 +1,282 @@
+package cleanhttp
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractTitle(t *testing.T) {
+	require.Equal(t, "Invalid URL", ExtractTitle("<html><head><title>Invalid URL</title></head></html>"))
+	require.Equal(t, "A & B", ExtractTitle("<TITLE lang=\"en\">\n  A &amp; B\n</TITLE>"))
+	require.Empty(t, ExtractTitle("<html><body>no title</body></html>"))
+}
+
+// benchmarkTitleBody is a page with its title after some head content
+var benchmarkTitleBody = "<html><head><meta charset=\"utf-8\"><link rel=\"stylesheet\" href=\"/app.css\">" +
+	"<title>Attention Required! | Cloudflare</title></head><body>" + strings.Repeat("<p>content</p>", 200) + "</body></html>"
+
+func BenchmarkExtractTitle(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		ExtractTitle(benchmarkTitleBody)
+	}
+}
+
+// BenchmarkExtractTitlePerCall compiles the title regex on every call,
+// the pattern ExtractTitle avoids
+func BenchmarkExtractTitlePerCall(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		re := regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+		if matches := re.FindStringSubmatch(benchmarkTitleBody); len(matches) > 1 {
+			_ = strings.TrimSpace(html.UnescapeString(matches[1]))
+		}
+	}
+}
+
+func TestMatchHTTPResponseTrailers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Edge-Status")
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("upstream failed"))
+		w.Header().Set("X-Edge-Status", "proxy-error")
+	}))
+	defer server.Close()
+
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	err = matcher.AddRules([]byte(`{"services": {"edge_proxy": {
+		"http_status_code": "502",
+		"http_trailer": {"X-Edge-Status": "proxy-error"}
+	}}}`))
+	require.NoError(t, err)
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	got, err := matcher.MatchHTTPResponse(resp)
+	require.NoError(t, err)
+	require.Equal(t, []string{"edge_proxy"}, got)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "upstream failed", string(body), "body should be restored for the caller")
+
+	got = matcher.Match(Response{StatusCode: 502})
+	require.Empty(t, got, "missing trailer should not match")
+}
+
+func TestMatchHeaderMultiValue(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("varnish_via", RuleJSON{
+		HTTPHeader: map[string]HeaderPatterns{"Via": {"1.1 varnish"}},
+	}))
+	require.NoError(t, matcher.AddRule("joined_via", RuleJSON{
+		HTTPHeader: map[string]HeaderPatterns{"Via": {"1.1 google, 1.1 varnish"}},
+	}))
+
+	h := http.Header{}
+	h.Add("Via", "1.1 google")
+	h.Add("Via", "1.1 varnish (Varnish/6.0)")
+
+	got := matcher.MatchHeader(200, h, "ok", "")
+	require.Equal(t, []string{"varnish_via"}, got, "patterns should match individual values, not the joined header")
+}
+
+func TestMatchHTTPResponseMethod(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte("<html><title>Method Blocked</title></html>"))
+	}))
+	defer server.Close()
+
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("trace_block", RuleJSON{
+		HTTPMethod:     []string{"trace"},
+		HTTPStatusCode: "403",
+		HTTPTitle:      "Method Blocked",
+	}))
+
+	for method, want := range map[string][]string{http.MethodTrace: {"trace_block"}, http.MethodGet: nil} {
+		req, err := http.NewRequest(method, server.URL, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		got, err := matcher.MatchHTTPResponse(resp)
+		require.NoError(t, err)
+		require.Equal(t, want, got, method)
+	}
+}
+
+func TestMatchHTTPResponseHeaderCount(t *testing.T) {
+	rules := []byte(`{"services": {"two_proxies": {"http_header_count": {"Via": 2}}}}`)
+	raw := "HTTP/1.1 200 OK\r\nVia: 1.1 varnish\r\nVia: 1.1 google\r\nContent-Length: 0\r\n\r\n"
+
+	for _, opts := range [][]Option{nil, {WithTitleOnly(true)}} {
+		matcher, err := newMatcher(rules, opts)
+		require.NoError(t, err)
+
+		resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(raw)), nil)
+		require.NoError(t, err)
+		got, err := matcher.MatchHTTPResponse(resp)
+		require.NoError(t, err)
+		require.Equal(t, []string{"two_proxies"}, got)
+	}
+
+	matcher, err := newMatcher(rules, nil)
+	require.NoError(t, err)
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(raw)), nil)
+	require.NoError(t, err)
+	parsed, err := ParseResponse(resp)
+	require.NoError(t, err)
+	require.Equal(t, [][2]string{{"Content-Length", "0"}, {"Via", "1.1 varnish"}, {"Via", "1.1 google"}}, parsed.HeaderPairs)
+	require.Equal(t, []string{"two_proxies"}, matcher.Match(parsed))
+}
+
+func TestMatchHTTPResponseStatusReason(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("legacy_waf", RuleJSON{
+		HTTPStatusCode:   "403",
+		HTTPStatusReason: "blocked by waf",
+	}))
+
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader("HTTP/1.1 403 Blocked by WAF\r\nContent-Length: 0\r\n\r\n")), nil)
+	require.NoError(t, err)
+	parsed, err := ParseResponse(resp)
+	require.NoError(t, err)
+	require.Equal(t, "Blocked by WAF", parsed.StatusReason)
+	require.Equal(t, []string{"legacy_waf"}, matcher.Match(parsed))
+
+	resp, err = http.ReadResponse(bufio.NewReader(strings.NewReader("HTTP/1.1 403 Forbidden\r\nContent-Length: 0\r\n\r\n")), nil)
+	require.NoError(t, err)
+	got, err := matcher.MatchHTTPResponse(resp)
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
+
+func TestMatchHTTPResponseReflectedHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", r.Header.Get("X-Request-Id"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("echo_proxy", RuleJSON{
+		HTTPReflectedHeader: []string{"X-Request-Id"},
+	}))
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Request-Id", "cleanhttp-probe-1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	got, err := matcher.MatchHTTPResponse(resp)
+	require.NoError(t, err)
+	require.Equal(t, []string{"echo_proxy"}, got)
+
+	resp, err = http.Get(server.URL)
+	require.NoError(t, err)
+	got, err = matcher.MatchHTTPResponse(resp)
+	require.NoError(t, err)
+	require.Empty(t, got, "nothing reflected without the request header")
+
+	require.Empty(t, matcher.Match(Response{
+		Headers:        map[string]string{"X-Request-Id": "other"},
+		RequestHeaders: map[string]string{"X-Request-Id": "cleanhttp-probe-1"},
+	}))
+}
+
+func TestMatchHTTPResponseTitleOnly(t *testing.T) {
+	page := "<html><head><title>Access Denied</title></head><body>" + strings.Repeat("x", 8192) + "Reference&#32;&#35;18</body></html>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	matcher, err := NewMatcher("", WithTitleOnly(true))
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("akamai_title", RuleJSON{HTTPTitle: "Access Denied"}))
+	require.NoError(t, matcher.AddRule("akamai_body", RuleJSON{HTTPBody: []string{"Reference&#32;&#35;"}}))
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	got, err := matcher.MatchHTTPResponse(resp)
+	require.NoError(t, err)
+	require.Equal(t, []string{"akamai_title"}, got, "body rules are skipped")
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, page, string(body), "body should be restored for the caller")
+}
+
+func TestWithTitleExtractor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<html><head><meta property="og:title" content="Access Denied"></head></html>`))
+	}))
+	defer server.Close()
+
+	var calls int
+	extract := func(body string) string {
+		calls++
+		if strings.Contains(body, `og:title" content="Access Denied"`) {
+			return "Access Denied"
+		}
+		return ""
+	}
+	matcher, err := NewMatcher("", WithTitleExtractor(extract))
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("og_block", RuleJSON{HTTPTitle: "Access Denied"}))
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	got, err := matcher.MatchHTTPResponse(resp)
+	require.NoError(t, err)
+	require.Equal(t, []string{"og_block"}, got)
+	require.Equal(t, 1, calls)
+}
+
+func TestMatchHTTPResponseTLS(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"akamai_edge": {"tls_san_contains": ["edgekey.net"]},
+		"cloudflare_ssl": {"tls_cn_contains": "cloudflaressl.com"}
+	}}`), nil)
+	require.NoError(t, err)
+
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("")),
+		TLS: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{
+			Subject:  pkix.Name{CommonName: "www.example.com"},
+			DNSNames: []string{"www.example.com", "www.example.com.EdgeKey.net"},
+		}}},
+	}
+	parsed, err := ParseResponse(resp)
+	require.NoError(t, err)
+	require.Equal(t, "www.example.com", parsed.TLSCommonName)
+	require.Equal(t, []string{"akamai_edge"}, matcher.Match(parsed))
+
+	require.Equal(t, []string{"cloudflare_ssl"}, matcher.Match(Response{TLSCommonName: "sni.cloudflaressl.com"}))
+	require.Empty(t, matcher.Match(Response{StatusCode: 200}))
+}
@@ -0,0 +1,219 @@
+package cleanhttp
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// input is a response prepared for matching against rules
+type input struct {
+	Response
+
+	// values holds every value of each lowercased header
+	values map[string][]string
+	// titles holds the title candidates of the response
+	titles []string
+
+	// Lowercased copies used by case-insensitive rules, only computed
+	// when the matcher has such rules
+	lowerBody   string
+	lowerTitles []string
+	lowerValues map[string][]string
+
+	// regexTime accumulates regex evaluation time when profiling
+	regexTime *time.Duration
+	// deadline is when the match budget runs out, zero if unbounded
+	deadline time.Time
+	// truncated is set once evaluation stopped because of the budget
+	truncated bool
+	// skipBody is set when the body was not read, failing body conditions
+	skipBody bool
+	// errorPage is the error page probed along with the response, nil
+	// unless matching a pair
+	errorPage *input
+	// partial is set when only a prefix of the body was read, failing
+	// trailer conditions and body conditions the rest of the body could
+	// invalidate
+	partial bool
+	// foldBody and foldHeaders compare the body and titles, and the
+	// header values, case-insensitively for every rule
+	foldBody    bool
+	foldHeaders bool
+
+	// json holds the body decoded as JSON once a rule needs it, nil
+	// when it is not valid JSON
+	json       any
+	jsonParsed bool
+}
+
+// newInput normalizes the response for matching. When header is not
+// nil its values are used for header matching instead of the single
+// values of the response headers. Otherwise header pairs, when present,
+// replace the response headers. The caller must hold the read lock.
+func (m *Matcher) newInput(resp Response, header http.Header) *input {
+	if header == nil && len(resp.HeaderPairs) > 0 {
+		header = make(http.Header, len(resp.HeaderPairs))
+		for _, pair := range resp.HeaderPairs {
+			header[pair[0]] = append(header[pair[0]], pair[1])
+		}
+		resp.Headers = flattenHeader(header)
+	}
+	if m.charsetDecode {
+		charset := bodyCharset(NormalizeHeaders(resp.Headers)["content-type"], resp.Body)
+		values := []*string{&resp.Body, &resp.Title}
+		resp.Titles = slices.Clone(resp.Titles)
+		for i := range resp.Titles {
+			values = append(values, &resp.Titles[i])
+		}
+		decodeCharset(charset, values...)
+	}
+	if m.sanitizeControl {
+		resp.Body = stripControl(resp.Body)
+		resp.Title = stripControl(resp.Title)
+		titles := make([]string, len(resp.Titles))
+		for i, title := range resp.Titles {
+			titles[i] = stripControl(title)
+		}
+		resp.Titles = titles
+	}
+	resp.Headers = NormalizeHeaders(resp.Headers)
+	resp.Trailers = NormalizeHeaders(resp.Trailers)
+	resp.RequestHeaders = NormalizeHeaders(resp.RequestHeaders)
+
+	in := &input{Response: resp, titles: titleCandidates(resp)}
+	if header != nil {
+		in.values = make(map[string][]string, len(header))
+		for k, v := range header {
+			key := strings.ToLower(k)
+			in.values[key] = append(in.values[key], v...)
+		}
+	} else {
+		in.values = headerValues(resp.Headers)
+	}
+
+	if m.caseInsensitiveRules > 0 {
+		in.lower()
+	}
+	return in
+}
+
+// lower computes the lowercased copies of the header values, body and
+// titles
+func (in *input) lower() {
+	in.lowerBody = strings.ToLower(in.Body)
+	in.lowerTitles = make([]string, len(in.titles))
+	for i, title := range in.titles {
+		in.lowerTitles[i] = strings.ToLower(title)
+	}
+	in.lowerValues = make(map[string][]string, len(in.values))
+	for k, values := range in.values {
+		lowered := make([]string, len(values))
+		for i, v := range values {
+			lowered[i] = strings.ToLower(v)
+		}
+		in.lowerValues[k] = lowered
+	}
+}
+
+// fields returns the header values, body and titles the rule is
+// compared against, lowercased for case-insensitive rules or when the
+// input folds case
+func (in *input) fields(rule Rule) (headers map[string][]string, body string, titles []string) {
+	headers, body, titles = in.values, in.Body, in.titles
+	if rule.CaseInsensitive || in.foldHeaders {
+		headers = in.lowerValues
+	}
+	if rule.CaseInsensitive || in.foldBody {
+		body, titles = in.lowerBody, in.lowerTitles
+	}
+	return headers, body, titles
+}
+
+// budgetSpent reports whether the match budget has run out, marking the
+// evaluation truncated when it has
+func (in *input) budgetSpent() bool {
+	if !in.truncated && !in.deadline.IsZero() && !time.Now().Before(in.deadline) {
+		in.truncated = true
+	}
+	return in.truncated
+}
+
+// age returns the seconds of the Age header. ok is false when the
+// header is missing or not a number of seconds.
+func (in *input) age() (seconds int, ok bool) {
+	values := in.values["age"]
+	if len(values) == 0 {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(strings.TrimSpace(values[0]))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return seconds, true
+}
+
+// bodyLength returns the length of the body, taken from the
+// Content-Length header when the body was not fully read. known is
+// false when the length cannot be determined.
+func (in *input) bodyLength() (length int, known bool) {
+	if !in.skipBody && !in.partial {
+		return len(in.Body), true
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(in.Headers["content-length"]))
+	if err != nil || length < 0 {
+		return 0, false
+	}
+	return length, true
+}
+
+// hasBody reports whether the body has content other than whitespace.
+// When the body was not fully read, a positive Content-Length counts.
+func (in *input) hasBody() bool {
+	if strings.TrimSpace(in.Body) != "" {
+		return true
+	}
+	if !in.skipBody && !in.partial {
+		return false
+	}
+	length, known := in.bodyLength()
+	return known && length > 0
+}
+
+// titleCandidates returns the title followed by the additional title
+// candidates, or a single empty title when there are none
+func titleCandidates(resp Response) []string {
+	if len(resp.Titles) == 0 {
+		return []string{resp.Title}
+	}
+	if resp.Title == "" {
+		return resp.Titles
+	}
+	return append([]string{resp.Title}, resp.Titles...)
+}
+
+// headerValues converts single valued headers to multi-valued headers
+func headerValues(headers map[string]string) map[string][]string {
+	values := make(map[string][]string, len(headers))
+	for k, v := range headers {
+		values[k] = []string{v}
+	}
+	return values
+}
+
+// stripControl removes control characters other than tabs and line
+// breaks, and invisible formatting characters
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return r
+		}
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, s)
+}
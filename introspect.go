@@ -0,0 +1,79 @@
+package cleanhttp
+
+import (
+	"slices"
+	"strings"
+)
+
+// Providers returns the names of the providers with a rule in the
+// matcher, sorted
+func (m *Matcher) Providers() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return sortedKeys(m.rules)
+}
+
+// RulesUsingHeader returns the sorted names of the providers whose
+// rules, including their match groups and on_404 block, check the
+// header. Besides http_header and http_header_present, keys reading a
+// specific header count, e.g. http_cookie for Set-Cookie or http_vary
+// for Vary.
+func (m *Matcher) RulesUsingHeader(header string) []string {
+	header = strings.ToLower(header)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var providers []string
+	for _, provider := range sortedKeys(m.rules) {
+		if slices.Contains(m.rules[provider].headersUsed(m.techHeaders), header) {
+			providers = append(providers, provider)
+		}
+	}
+	return providers
+}
+
+// headersUsed returns the lowercased headers the rule checks, tech_stack
+// conditions reading the given tech headers
+func (r Rule) headersUsed(techHeaders []TechHeader) []string {
+	var headers []string
+	for header := range r.Headers {
+		headers = append(headers, header)
+	}
+	for header := range r.HeaderCounts {
+		headers = append(headers, header)
+	}
+	headers = append(headers, r.HeadersPresent...)
+	headers = append(headers, r.ReflectedHeaders...)
+	if len(r.TechStack) > 0 {
+		for _, techHeader := range techHeaders {
+			headers = append(headers, techHeader.Header)
+		}
+	}
+	if len(r.Vary) > 0 {
+		headers = append(headers, "vary")
+	}
+	if len(r.Cookies) > 0 {
+		headers = append(headers, "set-cookie")
+	}
+	if r.LinkRel != "" || len(r.LinkContains) > 0 {
+		headers = append(headers, "link")
+	}
+	if r.AgeMin != 0 || r.AgeMax != 0 {
+		headers = append(headers, "age")
+	}
+	if len(r.ContentTypes) > 0 {
+		headers = append(headers, "content-type")
+	}
+	if r.MinSecurityHeaders > 0 {
+		headers = append(headers, securityHeaders...)
+	}
+	for _, group := range r.Groups {
+		headers = append(headers, group.headersUsed(techHeaders)...)
+	}
+	if r.On404 != nil {
+		headers = append(headers, r.On404.headersUsed(techHeaders)...)
+	}
+	return headers
+}
@@ -0,0 +1,33 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviders(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"vendor_b": {"http_status_code": "403"},
+		"vendor_a": {"http_status_code": "503"}
+	}}`), nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"vendor_a", "vendor_b"}, matcher.Providers())
+}
+
+func TestRulesUsingHeader(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	server := matcher.RulesUsingHeader("Server")
+	require.Contains(t, server, "cloudflare")
+	require.Contains(t, server, "cloudflare_redirection")
+	require.NotContains(t, server, "datadome")
+	require.IsIncreasing(t, server)
+
+	// Headers checked inside match groups and through cookie rules count
+	require.Contains(t, matcher.RulesUsingHeader("x-datadome"), "datadome")
+	require.Contains(t, matcher.RulesUsingHeader("set-cookie"), "perimeterx")
+
+	require.Empty(t, matcher.RulesUsingHeader("x-unused"))
+}
@@ -0,0 +1,92 @@
+package cleanhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ResponseRecord is the JSON document accepted by MatchFromJSON, as
+// stored by observability pipelines:
+//
+//	{
+//	  "status": 403,
+//	  "headers": {"Server": "cloudflare", "Via": ["1.1 a", "1.1 b"]},
+//	  "body": "...",
+//	  "title": "...",
+//	  "request_url": "https://example.com/"
+//	}
+//
+// Header values are strings, numbers, booleans or arrays of them. The
+// title is extracted from the body when absent.
+type ResponseRecord struct {
+	Status     int                          `json:"status"`
+	Method     string                       `json:"method,omitempty"`
+	Headers    map[string]RecordHeaderValue `json:"headers,omitempty"`
+	Body       string                       `json:"body,omitempty"`
+	Title      string                       `json:"title,omitempty"`
+	RequestURL string                       `json:"request_url,omitempty"`
+}
+
+// RecordHeaderValue holds the values of a header in a ResponseRecord.
+// In JSON it is a single value or an array, possibly empty. Numbers and
+// booleans are kept as written, and null stands for no value.
+type RecordHeaderValue []string
+
+// UnmarshalJSON decodes header values from a scalar or an array
+func (v *RecordHeaderValue) UnmarshalJSON(data []byte) error {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil || elements == nil {
+		elements = []json.RawMessage{data}
+	}
+
+	values := RecordHeaderValue{}
+	for _, element := range elements {
+		var value any
+		if err := json.Unmarshal(element, &value); err != nil {
+			return err
+		}
+		switch value := value.(type) {
+		case nil:
+		case string:
+			values = append(values, value)
+		case float64, bool:
+			values = append(values, string(element))
+		default:
+			return fmt.Errorf("header value must be a string, a number, a boolean or an array of them, got %s", element)
+		}
+	}
+	*v = values
+	return nil
+}
+
+// MatchFromJSON returns the names of WAF/CDN providers that match the
+// response described by a ResponseRecord JSON document
+func (m *Matcher) MatchFromJSON(data []byte) ([]string, error) {
+	var record ResponseRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("parsing response record: %w", err)
+	}
+
+	header := make(http.Header, len(record.Headers))
+	for name, values := range record.Headers {
+		header[name] = append(header[name], values...)
+	}
+	resp := Response{
+		StatusCode: record.Status,
+		Method:     record.Method,
+		Headers:    flattenHeader(header),
+		Body:       record.Body,
+		Title:      record.Title,
+		RequestURL: record.RequestURL,
+	}
+	if resp.Title == "" {
+		resp.Title = m.extractTitle(resp.Body)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matches, _ := m.matchInput(m.newInput(resp, header))
+	return m.resultNames(matches), nil
+}
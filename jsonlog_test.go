@@ -0,0 +1,75 @@
+package cleanhttp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchFromJSON(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	record := []byte(`{
+		"status": 400,
+		"headers": {"Server": ["CloudFront"], "Via": "1.1 abc.cloudfront.net (CloudFront)"},
+		"body": "<html><head><title>ERROR: The request could not be satisfied</title></head>Generated by cloudfront (CloudFront)</html>",
+		"request_url": "https://example.com/admin"
+	}`)
+	got, err := matcher.MatchFromJSON(record)
+	require.NoError(t, err)
+	require.Equal(t, []string{"cloudfront"}, got)
+
+	got, err = matcher.MatchFromJSON([]byte(`{"status": 200, "headers": {"Server": "nginx"}}`))
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	_, err = matcher.MatchFromJSON([]byte(`{"status": 403, "headers": {"Server": {"name": "cloudflare"}}}`))
+	require.ErrorContains(t, err, "header value must be a string, a number, a boolean or an array of them")
+}
+
+func TestRecordHeaderValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    RecordHeaderValue
+		wantErr bool
+	}{
+		{name: "string", json: `"cloudflare"`, want: RecordHeaderValue{"cloudflare"}},
+		{name: "array", json: `["1.1 a", "1.1 b"]`, want: RecordHeaderValue{"1.1 a", "1.1 b"}},
+		{name: "empty array", json: `[]`, want: RecordHeaderValue{}},
+		{name: "number", json: `1024`, want: RecordHeaderValue{"1024"}},
+		{name: "float", json: `0.5`, want: RecordHeaderValue{"0.5"}},
+		{name: "boolean", json: `true`, want: RecordHeaderValue{"true"}},
+		{name: "mixed array", json: `["max-age=0", 60, null]`, want: RecordHeaderValue{"max-age=0", "60"}},
+		{name: "null", json: `null`, want: RecordHeaderValue{}},
+		{name: "object", json: `{"a": "b"}`, wantErr: true},
+		{name: "nested array", json: `[["a"]]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got RecordHeaderValue
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMatchFromJSONHeaderShapes(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	got, err := matcher.MatchFromJSON([]byte(`{
+		"status": 503,
+		"headers": {"Server": "cloudflare", "Content-Length": 16, "X-Empty": [], "X-Debug": false},
+		"body": "error code: 1020"
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"cloudflare"}, got)
+}
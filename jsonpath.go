@@ -0,0 +1,70 @@
+package cleanhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// validateJSONPath checks the syntax of a dotted JSON path such as
+// fault.detail.errorcode or errors.0.message
+func validateJSONPath(path string) error {
+	if path == "" {
+		return fmt.Errorf("empty http_json path")
+	}
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			return fmt.Errorf("invalid http_json path %q", path)
+		}
+	}
+	return nil
+}
+
+// jsonValue returns the value at the dotted path in the body decoded as
+// JSON. Keys select object members and numbers select array elements.
+// Strings are returned as is, other values as JSON.
+func (in *input) jsonValue(path string) (string, bool) {
+	if !in.jsonParsed {
+		in.jsonParsed = true
+		if err := json.Unmarshal([]byte(in.Body), &in.json); err != nil {
+			in.json = nil
+		}
+	}
+
+	value := in.json
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]any:
+			child, ok := node[key]
+			if !ok {
+				return "", false
+			}
+			value = child
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", false
+			}
+			value = node[index]
+		default:
+			return "", false
+		}
+	}
+
+	if s, ok := value.(string); ok {
+		return s, true
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+	return string(encoded), true
+}
+
+// matchJSON reports whether the value at the path exists and contains
+// the pattern
+func (in *input) matchJSON(path, pattern string) bool {
+	value, ok := in.jsonValue(path)
+	return ok && strings.Contains(value, pattern)
+}
@@ -0,0 +1,38 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONValue(t *testing.T) {
+	in := &input{Response: Response{Body: `{"fault": {"faultstring": "Invalid ApiKey", "detail": {"errorcode": "oauth.v2.InvalidApiKey"}}, "errors": [{"code": 401}], "ok": false}`}}
+
+	tests := []struct {
+		path  string
+		want  string
+		found bool
+	}{
+		{path: "fault.faultstring", want: "Invalid ApiKey", found: true},
+		{path: "fault.detail.errorcode", want: "oauth.v2.InvalidApiKey", found: true},
+		{path: "errors.0.code", want: "401", found: true},
+		{path: "ok", want: "false", found: true},
+		{path: "errors.1.code"},
+		{path: "fault.missing"},
+		{path: "fault.faultstring.nested"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, found := in.jsonValue(tt.path)
+			require.Equal(t, tt.found, found)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	_, found := (&input{Response: Response{Body: "<html>"}}).jsonValue("message")
+	require.False(t, found)
+
+	require.Error(t, validateJSONPath("fault..detail"))
+	require.NoError(t, validateJSONPath("errors.0.message"))
+}
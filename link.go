@@ -0,0 +1,96 @@
+package cleanhttp
+
+import (
+	"slices"
+	"strings"
+)
+
+// link is a single entry of a Link header
+type link struct {
+	// URL is the lowercased target between the angle brackets
+	URL string
+	// Rels holds the lowercased relation types of the rel parameter
+	Rels []string
+}
+
+// parseLinks parses the entries of Link header values, which may each
+// hold several comma separated links with parameters, e.g.
+//
+//	<https://cdn.example.com>; rel=preconnect, </app.css>; rel="preload"; as=style
+//
+// Entries without an angle bracketed target are skipped.
+func parseLinks(values []string) []link {
+	var links []link
+	for _, value := range values {
+		for value != "" {
+			start := strings.IndexByte(value, '<')
+			if start < 0 {
+				break
+			}
+			end := strings.IndexByte(value[start:], '>')
+			if end < 0 {
+				break
+			}
+			current := link{URL: strings.ToLower(strings.TrimSpace(value[start+1 : start+end]))}
+			value = value[start+end+1:]
+
+			var params string
+			params, value = splitLinkParams(value)
+			for _, param := range strings.Split(params, ";") {
+				name, arg, _ := strings.Cut(param, "=")
+				if !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+				arg = strings.Trim(strings.TrimSpace(arg), `"`)
+				current.Rels = append(current.Rels, strings.Fields(strings.ToLower(arg))...)
+			}
+			links = append(links, current)
+		}
+	}
+	return links
+}
+
+// splitLinkParams returns the parameters following a link target up to
+// the comma ending the entry, ignoring commas in quoted strings, and
+// the remaining entries
+func splitLinkParams(value string) (params, rest string) {
+	quoted := false
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			if quoted {
+				i++
+			}
+		case ',':
+			if !quoted {
+				return value[:i], value[i+1:]
+			}
+		}
+	}
+	return value, ""
+}
+
+// matchLinks checks that a link with the relation type, when set,
+// exists, and that for each pattern such a link targets a URL
+// containing it
+func matchLinks(links []link, rel string, patterns []string) bool {
+	candidates := links
+	if rel != "" {
+		candidates = slices.DeleteFunc(slices.Clone(links), func(l link) bool {
+			return !slices.Contains(l.Rels, rel)
+		})
+		if len(candidates) == 0 {
+			return false
+		}
+	}
+	for _, pattern := range patterns {
+		if !slices.ContainsFunc(candidates, func(l link) bool {
+			return strings.Contains(l.URL, pattern)
+		}) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package cleanhttp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLinks(t *testing.T) {
+	links := parseLinks([]string{
+		`<https://CDN.Provider.com>; rel=preconnect, </app.css>; rel="preload stylesheet"; as=style`,
+		`</next>; title="a, b"; rel=next`,
+		`malformed`,
+	})
+	require.Equal(t, []link{
+		{URL: "https://cdn.provider.com", Rels: []string{"preconnect"}},
+		{URL: "/app.css", Rels: []string{"preload", "stylesheet"}},
+		{URL: "/next", Rels: []string{"next"}},
+	}, links)
+}
+
+func TestMatchLink(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"provider_cdn": {"http_link_rel": "preconnect", "http_link_contains": ["cdn.provider.com"]}
+	}}`), nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		links []string
+		want  []string
+	}{
+		{
+			name:  "preconnect to provider",
+			links: []string{`<https://cdn.provider.com>; rel=preconnect`},
+			want:  []string{"provider_cdn"},
+		},
+		{
+			name:  "second header value",
+			links: []string{`</style.css>; rel=preload; as=style`, `<https://fonts.example.com>; rel=preconnect, <https://cdn.provider.com>; rel="preconnect"`},
+			want:  []string{"provider_cdn"},
+		},
+		{
+			name:  "other relation",
+			links: []string{`<https://cdn.provider.com/app.js>; rel=preload; as=script`},
+			want:  nil,
+		},
+		{
+			name:  "no link header",
+			links: nil,
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{"Link": tt.links}
+			require.Equal(t, tt.want, matcher.MatchHeader(200, header, "", ""))
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package cleanhttp
+
+import (
+	"bufio"
+	"fmt"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadResponseFromFiles builds a Response from a response saved as a
+// header file, holding the status line and headers as written by
+// curl -D, and a body file, to test rules offline against saved
+// artifacts. When the header file holds several responses, as for
+// redirects followed or interim 100 Continue responses, the last one is
+// used. Folded header lines are joined to the preceding header. The
+// body file and request URL are optional.
+func LoadResponseFromFiles(headerFile, bodyFile, requestURL string) (Response, error) {
+	data, err := os.ReadFile(headerFile)
+	if err != nil {
+		return Response{}, fmt.Errorf("reading header file: %w", err)
+	}
+	resp, err := parseHeaderBlock(lastHeaderBlock(string(data)))
+	if err != nil {
+		return Response{}, fmt.Errorf("parsing header file %s: %w", headerFile, err)
+	}
+
+	if bodyFile != "" {
+		body, err := os.ReadFile(bodyFile)
+		if err != nil {
+			return Response{}, fmt.Errorf("reading body file: %w", err)
+		}
+		resp.Body = string(body)
+		resp.Title = ExtractTitle(resp.Body)
+	}
+	resp.RequestURL = requestURL
+	return resp, nil
+}
+
+// lastHeaderBlock returns the last block of the header dump starting
+// with a status line, with line endings normalized to CRLF
+func lastHeaderBlock(dump string) string {
+	dump = strings.ReplaceAll(dump, "\r\n", "\n")
+	var block string
+	for _, candidate := range strings.Split(dump, "\n\n") {
+		candidate = strings.TrimLeft(candidate, "\n")
+		if strings.HasPrefix(candidate, "HTTP/") {
+			block = candidate
+		}
+	}
+	return strings.ReplaceAll(strings.TrimRight(block, "\n"), "\n", "\r\n") + "\r\n\r\n"
+}
+
+// parseHeaderBlock parses a status line followed by headers
+func parseHeaderBlock(block string) (Response, error) {
+	reader := textproto.NewReader(bufio.NewReader(strings.NewReader(block)))
+	statusLine, err := reader.ReadLine()
+	if err != nil {
+		return Response{}, fmt.Errorf("reading status line: %w", err)
+	}
+	proto, status, _ := strings.Cut(statusLine, " ")
+	if !strings.HasPrefix(proto, "HTTP/") {
+		return Response{}, fmt.Errorf("malformed status line %q", statusLine)
+	}
+	code, reason, _ := strings.Cut(strings.TrimSpace(status), " ")
+	statusCode, err := strconv.Atoi(code)
+	if err != nil || len(code) != 3 {
+		return Response{}, fmt.Errorf("malformed status code in %q", statusLine)
+	}
+
+	header, err := reader.ReadMIMEHeader()
+	if err != nil {
+		return Response{}, fmt.Errorf("reading headers: %w", err)
+	}
+	resp := Response{
+		StatusCode:   statusCode,
+		StatusReason: strings.TrimSpace(reason),
+		Headers:      make(map[string]string, len(header)),
+		RawHeaders:   strings.TrimRight(block, "\r\n"),
+	}
+	for name, values := range header {
+		resp.Headers[name] = strings.Join(values, ", ")
+	}
+	return resp, nil
+}
@@ -0,0 +1,58 @@
+package cleanhttp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadResponseFromFiles(t *testing.T) {
+	resp, err := LoadResponseFromFiles("testdata/cloudflare.headers", "testdata/cloudflare.body", "https://example.com/")
+	require.NoError(t, err)
+	require.Equal(t, 503, resp.StatusCode)
+	require.Equal(t, "Service Unavailable", resp.StatusReason)
+	require.Equal(t, "cloudflare", resp.Headers["Server"])
+	require.Equal(t, "first second", resp.Headers["X-Folded"])
+	require.NotContains(t, resp.Headers, "Location")
+	require.Equal(t, "error code: 1016", resp.Body)
+	require.Equal(t, "https://example.com/", resp.RequestURL)
+
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.Equal(t, []string{"cloudflare"}, matcher.Match(resp))
+}
+
+func TestLoadResponseFromFilesErrors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		headers string
+		wantErr string
+	}{
+		{name: "no status line", headers: "Server: nginx\n\n", wantErr: "malformed status line"},
+		{name: "bad status code", headers: "HTTP/1.1 abc Bad\n\n", wantErr: "malformed status code"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadResponseFromFiles(write(tt.name, tt.headers), "", "")
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	_, err := LoadResponseFromFiles(filepath.Join(dir, "missing"), "", "")
+	require.ErrorContains(t, err, "reading header file")
+
+	resp, err := LoadResponseFromFiles(write("lf", "HTTP/1.1 200 OK\nServer: nginx\n"), "", "")
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	require.Equal(t, "nginx", resp.Headers["Server"])
+	require.Empty(t, resp.Body)
+}
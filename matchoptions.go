@@ -0,0 +1,86 @@
+package cleanhttp
+
+import "strings"
+
+// MatchOptions overrides how a single call matches the response,
+// without rebuilding the matcher
+type MatchOptions struct {
+	// CaseInsensitiveBody compares body and title patterns
+	// case-insensitively, as if every rule set case_insensitive.
+	// Regex patterns keep their own flags.
+	CaseInsensitiveBody bool
+	// CaseInsensitiveHeaders compares header value patterns
+	// case-insensitively
+	CaseInsensitiveHeaders bool
+	// MaxBodyScan limits body conditions to the first bytes of the
+	// body, zero scans the whole body. A cut body is matched as a
+	// prefix, as by MatchEarly.
+	MaxBodyScan int
+}
+
+// MatchWithOptions returns the names of WAF/CDN providers that match
+// the response, applying the per-call options. Match is equivalent to
+// MatchWithOptions with zero options.
+func (m *Matcher) MatchWithOptions(resp Response, opts MatchOptions) []string {
+	partial := opts.MaxBodyScan > 0 && len(resp.Body) > opts.MaxBodyScan
+	if partial {
+		resp.Body = resp.Body[:opts.MaxBodyScan]
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	in := m.newInput(resp, nil)
+	in.partial = partial
+	in.foldBody = opts.CaseInsensitiveBody
+	in.foldHeaders = opts.CaseInsensitiveHeaders
+	if (in.foldBody || in.foldHeaders) && in.lowerValues == nil {
+		in.lower()
+	}
+	matches, _ := m.matchInput(in)
+	return m.resultNames(matches)
+}
+
+// folded returns a copy of the rule with its body and title, or header
+// value, patterns lowercased, for matching against an input that folds
+// case. Regexes keep their own flags.
+func (r Rule) folded(body, headers bool) Rule {
+	if r.CaseInsensitive {
+		return r
+	}
+	if headers && len(r.Headers) > 0 {
+		lowered := make(map[string][]string, len(r.Headers))
+		for header, patterns := range r.Headers {
+			lowered[header] = lowerAll(patterns)
+		}
+		r.Headers = lowered
+	}
+	if body {
+		r.BodyContains = lowerAll(r.BodyContains)
+		r.BodyLines = lowerAll(r.BodyLines)
+		r.TitleExact = strings.ToLower(r.TitleExact)
+		if r.TitleFuzzy != nil {
+			r.TitleFuzzy = &FuzzyTitle{Title: strings.ToLower(r.TitleFuzzy.Title), MaxDistance: r.TitleFuzzy.MaxDistance}
+		}
+	}
+	if len(r.Groups) > 0 {
+		groups := make([]Rule, len(r.Groups))
+		for i, group := range r.Groups {
+			groups[i] = group.folded(body, headers)
+		}
+		r.Groups = groups
+	}
+	return r
+}
+
+// lowerAll returns a lowercased copy of the strings
+func lowerAll(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	lowered := make([]string, len(values))
+	for i, v := range values {
+		lowered[i] = strings.ToLower(v)
+	}
+	return lowered
+}
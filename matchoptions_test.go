@@ -0,0 +1,66 @@
+package cleanhttp
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchWithOptions(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"blocked": {"http_body": ["Access Denied"]},
+		"edge": {"http_header": {"Server": "EdgeProxy"}},
+		"grouped": {"match_groups": [{"http_title": "Request Blocked"}]}
+	}}`), nil)
+	require.NoError(t, err)
+
+	resp := Response{
+		StatusCode: 403,
+		Headers:    map[string]string{"Server": "edgeproxy/2.1"},
+		Title:      "REQUEST BLOCKED",
+		Body:       "<h1>ACCESS DENIED</h1>",
+	}
+	require.Empty(t, matcher.Match(resp))
+	require.Empty(t, matcher.MatchWithOptions(resp, MatchOptions{}))
+	require.Equal(t, []string{"edge"}, matcher.MatchWithOptions(resp, MatchOptions{CaseInsensitiveHeaders: true}))
+	require.ElementsMatch(t, []string{"blocked", "grouped"}, matcher.MatchWithOptions(resp, MatchOptions{CaseInsensitiveBody: true}))
+	require.Empty(t, matcher.Match(resp))
+
+	resp.Body = "<h1>Welcome</h1>" + "<p>Access Denied</p>"
+	require.Equal(t, []string{"blocked"}, matcher.MatchWithOptions(resp, MatchOptions{}))
+	require.Empty(t, matcher.MatchWithOptions(resp, MatchOptions{MaxBodyScan: 16}))
+}
+
+func TestMatchWithOptionsTitlePatterns(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"plain_regex": {"http_title_regex": "^Access Denied"},
+		"plain_fuzzy": {"http_title_fuzzy": {"title": "Access Denied", "max_distance": 1}}
+	}}`), nil)
+	require.NoError(t, err)
+
+	for _, title := range []string{"Access Denied", "Access Denied!"} {
+		resp := Response{StatusCode: 403, Title: title}
+		want := matcher.Match(resp)
+		require.NotEmpty(t, want)
+		require.ElementsMatch(t, want, matcher.MatchWithOptions(resp, MatchOptions{CaseInsensitiveBody: true}))
+	}
+	require.Equal(t, []string{"plain_fuzzy"}, matcher.MatchWithOptions(Response{StatusCode: 403, Title: "ACCESS DENIED"}, MatchOptions{CaseInsensitiveBody: true}))
+}
+
+func TestMatchWithOptionsMaxBodyScan(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"small_page": {"http_body": ["blocked"], "http_body_length_max": 100},
+		"page_end": {"http_body_regex": ["blocked\\s*$"]}
+	}}`), nil)
+	require.NoError(t, err)
+
+	body := "<html>blocked" + strings.Repeat(" ", 5000) + "</html>"
+	resp := Response{StatusCode: 403, Headers: map[string]string{"Content-Length": strconv.Itoa(len(body))}, Body: body}
+	require.Empty(t, matcher.MatchWithOptions(resp, MatchOptions{MaxBodyScan: 50}))
+
+	resp.Body = "<html>blocked</html>"
+	resp.Headers["Content-Length"] = strconv.Itoa(len(resp.Body))
+	require.Equal(t, []string{"small_page"}, matcher.MatchWithOptions(resp, MatchOptions{MaxBodyScan: 50}))
+}
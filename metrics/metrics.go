@@ -0,0 +1,66 @@
+// Package metrics exposes the per-provider statistics of a cleanhttp
+// matcher as Prometheus metrics. It lives in its own module so that
+// only programs exporting metrics depend on the Prometheus client.
+package metrics
+
+import (
+	"strings"
+
+	"github.com/projectdiscovery/cleanhttp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	evaluationsDesc = prometheus.NewDesc(
+		"cleanhttp_rule_evaluations_total",
+		"Number of responses the provider rule was evaluated against.",
+		[]string{"provider", "category"}, nil,
+	)
+	matchesDesc = prometheus.NewDesc(
+		"cleanhttp_rule_matches_total",
+		"Number of responses the provider rule matched.",
+		[]string{"provider", "category"}, nil,
+	)
+	evalSecondsDesc = prometheus.NewDesc(
+		"cleanhttp_rule_evaluation_seconds_total",
+		"Total time spent evaluating the provider rule.",
+		[]string{"provider", "category"}, nil,
+	)
+	regexSecondsDesc = prometheus.NewDesc(
+		"cleanhttp_rule_regex_seconds_total",
+		"Total time spent evaluating the regex patterns of the provider rule.",
+		[]string{"provider", "category"}, nil,
+	)
+)
+
+// Collector is a prometheus.Collector reporting the statistics of a
+// matcher. The matcher must be created with profiling enabled, see
+// cleanhttp.WithProfiling, otherwise no metrics are reported.
+type Collector struct {
+	matcher *cleanhttp.Matcher
+}
+
+// NewCollector returns a collector for the statistics of the matcher
+func NewCollector(matcher *cleanhttp.Matcher) *Collector {
+	return &Collector{matcher: matcher}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- evaluationsDesc
+	ch <- matchesDesc
+	ch <- evalSecondsDesc
+	ch <- regexSecondsDesc
+}
+
+// Collect implements prometheus.Collector. The category label holds
+// the comma separated tags of the provider rule.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for provider, stats := range c.matcher.Profile() {
+		category := strings.Join(c.matcher.Tags(provider), ",")
+		ch <- prometheus.MustNewConstMetric(evaluationsDesc, prometheus.CounterValue, float64(stats.Evaluations), provider, category)
+		ch <- prometheus.MustNewConstMetric(matchesDesc, prometheus.CounterValue, float64(stats.Matches), provider, category)
+		ch <- prometheus.MustNewConstMetric(evalSecondsDesc, prometheus.CounterValue, stats.EvalTime.Seconds(), provider, category)
+		ch <- prometheus.MustNewConstMetric(regexSecondsDesc, prometheus.CounterValue, stats.RegexTime.Seconds(), provider, category)
+	}
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/projectdiscovery/cleanhttp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	matcher, err := cleanhttp.NewMatcher("", cleanhttp.WithProfiling(true))
+	require.NoError(t, err)
+
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(NewCollector(matcher)))
+
+	resp := cleanhttp.Response{Headers: map[string]string{"X-DataDome": "protected"}}
+	require.Equal(t, []string{"datadome"}, matcher.MatchCategory(resp, cleanhttp.CategoryBotManagement))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 4)
+
+	matches := func() float64 {
+		families, err := registry.Gather()
+		require.NoError(t, err)
+		for _, family := range families {
+			if family.GetName() != "cleanhttp_rule_matches_total" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				labels := make(map[string]string)
+				for _, label := range metric.GetLabel() {
+					labels[label.GetName()] = label.GetValue()
+				}
+				if labels["provider"] == "datadome" {
+					require.Equal(t, "bot-management", labels["category"])
+					return metric.GetCounter().GetValue()
+				}
+			}
+		}
+		t.Fatal("datadome matches not reported")
+		return 0
+	}
+	require.Equal(t, float64(1), matches())
+
+	matcher.MatchCategory(resp, cleanhttp.CategoryBotManagement)
+	require.Equal(t, float64(2), matches())
+
+	count, err := testutil.GatherAndCount(registry, "cleanhttp_rule_evaluations_total")
+	require.NoError(t, err)
+	require.Equal(t, len(matcher.Profile()), count)
+}
@@ -0,0 +1,47 @@
+package cleanhttp
+
+import "slices"
+
+// NearMiss is a rule that almost matched a response
+type NearMiss struct {
+	Provider string `json:"provider"`
+	// Met is the number of conditions of the rule the response meets
+	Met int `json:"met"`
+	// Missing lists the conditions of the rule the response does not
+	// meet
+	Missing []Condition `json:"missing"`
+}
+
+// NearMisses returns the rules that did not match the response but meet
+// at least one of their conditions and miss at most maxMissing of them,
+// to triage why a response was not classified. The near misses are
+// sorted by fewest missing conditions, then by provider.
+func (m *Matcher) NearMisses(resp Response, maxMissing int) []NearMiss {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	in := m.newInput(resp, nil)
+	now := m.clock()
+	var nearMisses []NearMiss
+	for _, provider := range sortedKeys(m.rules) {
+		matched, conditions := m.explainProvider(in, m.rules[provider], now)
+		if matched {
+			continue
+		}
+		nearMiss := NearMiss{Provider: provider}
+		for _, condition := range conditions {
+			if condition.Met() {
+				nearMiss.Met++
+			} else {
+				nearMiss.Missing = append(nearMiss.Missing, condition)
+			}
+		}
+		if nearMiss.Met > 0 && len(nearMiss.Missing) > 0 && len(nearMiss.Missing) <= maxMissing {
+			nearMisses = append(nearMisses, nearMiss)
+		}
+	}
+	slices.SortStableFunc(nearMisses, func(a, b NearMiss) int {
+		return len(a.Missing) - len(b.Missing)
+	})
+	return nearMisses
+}
@@ -0,0 +1,37 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNearMisses(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	resp := Response{
+		StatusCode: 400,
+		Headers:    map[string]string{"Server": "AkamaiGHost"},
+		Title:      "Bad Request",
+		Body:       "The requested URL \"[no URL]\", is invalid.",
+	}
+	require.Empty(t, matcher.Match(resp))
+
+	nearMisses := matcher.NearMisses(resp, 1)
+	require.NotEmpty(t, nearMisses)
+	require.Equal(t, "akamai", nearMisses[0].Provider)
+	require.Equal(t, 3, nearMisses[0].Met)
+	require.Equal(t, []Condition{{Signal: SignalTitle, Description: `title is "Invalid URL"`, Result: ResultFailed}}, nearMisses[0].Missing)
+	for _, nearMiss := range nearMisses {
+		require.Len(t, nearMiss.Missing, 1)
+	}
+
+	require.Empty(t, matcher.NearMisses(resp, 0))
+
+	resp.Title = "Invalid URL"
+	require.Equal(t, []string{"akamai"}, matcher.Match(resp))
+	for _, nearMiss := range matcher.NearMisses(resp, 10) {
+		require.NotEqual(t, "akamai", nearMiss.Provider)
+	}
+}
@@ -0,0 +1,239 @@
+package cleanhttp
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Option configures a Matcher
+type Option func(*Matcher)
+
+// WithMaxRegexInput sets how many bytes of a header, title or body
+// value rule regexes are matched against, longer values only being
+// searched in their first n bytes. Go regexes run in time linear in the
+// size of their program and input, so along with the compile-time limit
+// on program size this bounds the time of every regex evaluation.
+// By default, and with zero or less, regexes see the whole value.
+func WithMaxRegexInput(n int) Option {
+	return func(m *Matcher) {
+		m.maxRegexInput = n
+	}
+}
+
+// WithProfiling enables recording per-rule evaluation statistics,
+// retrievable with Matcher.Profile. Profiling is disabled by default.
+func WithProfiling(enabled bool) Option {
+	return func(m *Matcher) {
+		m.profiling = enabled
+	}
+}
+
+// WithStrict enables strict rule loading, which rejects unknown fields
+// and providers defined more than once in the same rules document
+func WithStrict(enabled bool) Option {
+	return func(m *Matcher) {
+		m.strict = enabled
+	}
+}
+
+// WithDefaultStatusRange sets the status code range applied to rules
+// that do not specify http_status_code. By default such rules match
+// any status code.
+func WithDefaultStatusRange(min, max int) Option {
+	return func(m *Matcher) {
+		m.defaultStatusMin = min
+		m.defaultStatusMax = max
+	}
+}
+
+// WithMatchBudget bounds the total time spent evaluating rules in a
+// single match. The budget is checked before each rule and each regex,
+// so a match can overrun it by at most one regex evaluation, which
+// WithMaxRegexInput bounds. Once the budget is spent the remaining
+// conditions are skipped, so results may be incomplete; MatchBudgeted
+// reports when that happened. Zero disables the budget.
+func WithMatchBudget(d time.Duration) Option {
+	return func(m *Matcher) {
+		m.matchBudget = d
+	}
+}
+
+// WithPriorities replaces the vendor priority table used by Classify
+func WithPriorities(priorities map[string]int) Option {
+	return func(m *Matcher) {
+		m.priorities = maps.Clone(priorities)
+	}
+}
+
+// WithTechHeaders adds headers read by tech_stack conditions and
+// Matcher.TechStack, after the default ones
+func WithTechHeaders(headers ...TechHeader) Option {
+	return func(m *Matcher) {
+		m.techHeaders = append(m.techHeaders, headers...)
+	}
+}
+
+// WithServerParsers adds parsers tried in order by Matcher.ServerInfo
+// before the generic product/version parser
+func WithServerParsers(parsers ...ServerParser) Option {
+	return func(m *Matcher) {
+		m.serverParsers = append(m.serverParsers, parsers...)
+	}
+}
+
+// WithLeakPatterns adds patterns checked by Matcher.DetectOriginLeaks,
+// after the default ones
+func WithLeakPatterns(patterns ...LeakPattern) Option {
+	return func(m *Matcher) {
+		m.leakPatterns = append(m.leakPatterns, patterns...)
+	}
+}
+
+// WithCacheSignatures adds signatures checked by Matcher.CacheInfo
+// before the default ones, so that they take precedence over the
+// generic x-cache and age signatures
+func WithCacheSignatures(signatures ...CacheSignature) Option {
+	return func(m *Matcher) {
+		m.cacheSignatures = append(slices.Clone(signatures), m.cacheSignatures...)
+	}
+}
+
+// WithProviderAliases adds provider names of other tools, lowercased,
+// that Matcher.Reconcile normalizes to the given rule names
+func WithProviderAliases(aliases map[string]string) Option {
+	return func(m *Matcher) {
+		for name, provider := range aliases {
+			m.providerAliases[strings.ToLower(name)] = provider
+		}
+	}
+}
+
+// WithBlockPageHeuristics replaces the heuristics used by
+// IsLikelyBlockPage
+func WithBlockPageHeuristics(heuristics BlockPageHeuristics) Option {
+	return func(m *Matcher) {
+		m.blockPageHeuristics = heuristics
+	}
+}
+
+// WithEnabledProviders restricts matching to the listed providers
+func WithEnabledProviders(names ...string) Option {
+	return func(m *Matcher) {
+		m.enabledProviders = providerSet(m.enabledProviders, names)
+	}
+}
+
+// WithDisabledProviders excludes the listed providers from matching.
+// It applies on top of WithEnabledProviders.
+func WithDisabledProviders(names ...string) Option {
+	return func(m *Matcher) {
+		m.disabledProviders = providerSet(m.disabledProviders, names)
+	}
+}
+
+// WithEnabledCategories restricts matching to rules carrying at least
+// one of the listed tags, such as waf or bot-management. Providers of
+// custom matchers have no tags and are excluded once categories are
+// enabled.
+func WithEnabledCategories(categories ...string) Option {
+	return func(m *Matcher) {
+		m.enabledCategories = providerSet(m.enabledCategories, categories)
+	}
+}
+
+// WithDenyByDefault makes matching return nothing until categories are
+// enabled with WithEnabledCategories, so embedded uses must opt in to
+// the detections they want instead of matching every rule by default
+func WithDenyByDefault(enabled bool) Option {
+	return func(m *Matcher) {
+		m.denyByDefault = enabled
+	}
+}
+
+// WithAliases renames providers in match results, mapping rule names
+// to canonical names, e.g. cf to cloudflare, so rulesets spelling
+// providers differently report the same names. Aliases apply to Match
+// and its variants and to MatchDetailed, after WithCollapseVariants.
+func WithAliases(aliases map[string]string) Option {
+	return func(m *Matcher) {
+		if m.aliases == nil {
+			m.aliases = make(map[string]string, len(aliases))
+		}
+		for name, canonical := range aliases {
+			m.aliases[name] = canonical
+		}
+	}
+}
+
+// WithTitleOnly makes MatchHTTPResponse read the body only up to the
+// end of the HTML head, or TitleOnlyReadLimit bytes, to extract the
+// title. Rules with body conditions never match in this mode.
+func WithTitleOnly(enabled bool) Option {
+	return func(m *Matcher) {
+		m.titleOnly = enabled
+	}
+}
+
+// WithCollapseVariants makes Match report the parent of variant rules,
+// such as cloudflare for cloudflare_redirection, once instead of each
+// matching variant
+func WithCollapseVariants(enabled bool) Option {
+	return func(m *Matcher) {
+		m.collapse = enabled
+	}
+}
+
+// WithSanitizeControl removes control and invisible formatting
+// characters, such as NUL or zero-width spaces, from the body and titles
+// before matching, so they cannot be used to evade substring rules. Tabs
+// and line breaks are kept. Body spans reported by MatchDetailed are
+// offsets into the sanitized body.
+func WithSanitizeControl(enabled bool) Option {
+	return func(m *Matcher) {
+		m.sanitizeControl = enabled
+	}
+}
+
+// WithTitleExtractor replaces ExtractTitle for the titles the matcher
+// extracts from bodies, e.g. in MatchHTTPResponse, so an existing HTML
+// parser can be used. A nil extractor restores ExtractTitle.
+func WithTitleExtractor(extract func(body string) string) Option {
+	return func(m *Matcher) {
+		if extract == nil {
+			extract = ExtractTitle
+		}
+		m.extractTitle = extract
+	}
+}
+
+// WithCharsetDecode transcodes the body and titles to UTF-8 before
+// matching, using the charset declared by the Content-Type header or a
+// meta tag, so rules written in UTF-8 match pages served as Shift-JIS,
+// GBK or Latin-1. Responses with an unknown charset are matched as is.
+func WithCharsetDecode(enabled bool) Option {
+	return func(m *Matcher) {
+		m.charsetDecode = enabled
+	}
+}
+
+// WithClock sets the function returning the current time, used to skip
+// rules outside their valid_from and valid_until window. It defaults to
+// time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(m *Matcher) {
+		m.clock = now
+	}
+}
+
+// providerSet adds the names to the set, creating it when needed
+func providerSet(set map[string]struct{}, names []string) map[string]struct{} {
+	if set == nil {
+		set = make(map[string]struct{}, len(names))
+	}
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,171 @@
+package cleanhttp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderFilters(t *testing.T) {
+	resp := Response{
+		StatusCode: 429,
+		Headers:    map[string]string{"Server": "cloudflare", "Retry-After": "60"},
+		Body:       "error code: 1015",
+	}
+
+	rateLimit := WithEnabledCategories("rate-limit")
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{
+			name: "opt-in category not enabled",
+			want: nil,
+		},
+		{
+			name: "no filters",
+			opts: []Option{rateLimit},
+			want: []string{"cloudflare_rate_limit", "generic_rate_limit"},
+		},
+		{
+			name: "disabled",
+			opts: []Option{rateLimit, WithDisabledProviders("generic_rate_limit")},
+			want: []string{"cloudflare_rate_limit"},
+		},
+		{
+			name: "enabled",
+			opts: []Option{rateLimit, WithEnabledProviders("generic_rate_limit", "akamai")},
+			want: []string{"generic_rate_limit"},
+		},
+		{
+			name: "enabled and disabled",
+			opts: []Option{
+				rateLimit,
+				WithEnabledProviders("cloudflare_rate_limit", "generic_rate_limit"),
+				WithDisabledProviders("cloudflare_rate_limit"),
+			},
+			want: []string{"generic_rate_limit"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher, err := NewMatcher("", tt.opts...)
+			require.NoError(t, err)
+			require.ElementsMatch(t, tt.want, matcher.Match(resp))
+		})
+	}
+}
+
+func TestWithCollapseVariants(t *testing.T) {
+	rules := []byte(`{"services": {
+		"cloudflare": {"http_header": {"Server": "cloudflare"}},
+		"cloudflare_redirection": {"parent": "cloudflare", "http_status_code": "301", "http_header": {"Server": "cloudflare"}}
+	}}`)
+	resp := Response{StatusCode: 301, Headers: map[string]string{"Server": "cloudflare"}}
+
+	matcher, err := newMatcher(rules, nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"cloudflare", "cloudflare_redirection"}, matcher.Match(resp))
+
+	collapsed, err := newMatcher(rules, []Option{WithCollapseVariants(true)})
+	require.NoError(t, err)
+	require.Equal(t, []string{"cloudflare"}, collapsed.Match(resp))
+
+	detailed := collapsed.MatchDetailed(resp).Results
+	require.Len(t, detailed, 2)
+	require.Equal(t, "cloudflare", detailed[0].Provider)
+	require.Empty(t, detailed[0].Parent)
+	require.Equal(t, "cloudflare_redirection", detailed[1].Provider)
+	require.Equal(t, "cloudflare", detailed[1].Parent)
+}
+
+func TestWithSanitizeControl(t *testing.T) {
+	rules := []byte(`{"services": {"generic_waf": {"http_body": ["access denied"], "http_title": "Blocked"}}}`)
+	resp := Response{StatusCode: 403, Title: "Blo\x00cked", Body: "<h1>acce\x00ss\u200b denied</h1>"}
+
+	matcher, err := newMatcher(rules, nil)
+	require.NoError(t, err)
+	require.Empty(t, matcher.Match(resp))
+
+	sanitized, err := newMatcher(rules, []Option{WithSanitizeControl(true)})
+	require.NoError(t, err)
+	require.Equal(t, []string{"generic_waf"}, sanitized.Match(resp))
+
+	detailed := sanitized.MatchDetailed(resp).Results
+	require.Len(t, detailed, 1)
+	require.Equal(t, []Span{{Start: 4, End: 17, Text: "access denied"}}, detailed[0].BodyMatches)
+}
+
+func TestRuleValidityWindow(t *testing.T) {
+	rules := []byte(`{"services": {
+		"old_signature": {"http_header": {"Server": "cloudflare"}, "valid_until": "2025-01-01T00:00:00Z"},
+		"new_signature": {"http_header": {"Server": "cloudflare"}, "valid_from": "2025-01-01T00:00:00Z"}
+	}}`)
+	resp := Response{Headers: map[string]string{"Server": "cloudflare"}}
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	matcher, err := newMatcher(rules, []Option{WithClock(func() time.Time { return now })})
+	require.NoError(t, err)
+	require.Equal(t, []string{"old_signature"}, matcher.Match(resp))
+
+	now = time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, []string{"new_signature"}, matcher.Match(resp), "expired rule should be skipped")
+
+	for _, rule := range []RuleJSON{
+		{ValidFrom: "yesterday"},
+		{ValidFrom: "2025-01-01T00:00:00Z", ValidUntil: "2024-01-01T00:00:00Z"},
+	} {
+		require.Error(t, matcher.AddRule("invalid", rule))
+	}
+}
+
+func TestDenyByDefault(t *testing.T) {
+	resp := Response{Headers: map[string]string{"X-DataDome": "protected", "X-Vercel-Id": "iad1::abc"}}
+
+	matcher, err := NewMatcher("", WithDenyByDefault(true))
+	require.NoError(t, err)
+	require.Empty(t, matcher.Match(resp))
+	require.Empty(t, matcher.MatchCategory(resp, CategoryBotManagement))
+
+	matcher, err = NewMatcher("", WithDenyByDefault(true), WithEnabledCategories("bot-management"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"datadome"}, matcher.Match(resp))
+
+	matcher, err = NewMatcher("", WithEnabledCategories("bot-management", "edge-compute"))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"datadome", "vercel"}, matcher.Match(resp))
+
+	matcher, err = NewMatcher("")
+	require.NoError(t, err)
+	require.Empty(t, matcher.Match(resp), "bot-management and edge-compute are opt-in")
+}
+
+func TestAliases(t *testing.T) {
+	rules := []byte(`{"services": {
+		"cf": {"http_header": {"Server": "cloudflare"}, "tags": ["cdn"]},
+		"cf_block": {"parent": "cf", "http_status_code": "403", "http_header": {"Server": "cloudflare"}, "tags": ["waf"]}
+	}}`)
+	matcher, err := newMatcher(rules, []Option{WithAliases(map[string]string{"cf": "cloudflare", "cf_block": "cloudflare_block"})})
+	require.NoError(t, err)
+
+	resp := Response{StatusCode: 403, Headers: map[string]string{"Server": "cloudflare"}}
+	require.ElementsMatch(t, []string{"cloudflare", "cloudflare_block"}, matcher.Match(resp))
+
+	detailed := matcher.MatchDetailed(resp).Results
+	require.Len(t, detailed, 2)
+	require.Equal(t, "cloudflare", detailed[0].Provider)
+	require.Equal(t, "cloudflare_block", detailed[1].Provider)
+	require.Equal(t, "cloudflare", detailed[1].Parent)
+	require.Equal(t, "cf_block", detailed[1].ID)
+
+	verdict := matcher.Classify(matcher.Match(resp))
+	require.Equal(t, "cloudflare", verdict.Provider)
+	require.Equal(t, []string{"cdn", "waf"}, verdict.Roles)
+
+	collapsing, err := newMatcher(rules, []Option{WithAliases(map[string]string{"cf": "cloudflare"}), WithCollapseVariants(true)})
+	require.NoError(t, err)
+	require.Equal(t, []string{"cloudflare"}, collapsing.Match(resp))
+}
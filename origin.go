@@ -0,0 +1,98 @@
+package cleanhttp
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// Leak is a response header suspected of exposing the origin server
+// behind a CDN or WAF
+type Leak struct {
+	Header string
+	Value  string
+	Reason string
+}
+
+// LeakPattern describes a header that may expose the origin server
+type LeakPattern struct {
+	// Header is the lowercased header to inspect, empty for all headers
+	Header string
+	// Reason describes the finding
+	Reason string
+	// Find returns the suspected values in the header value. When nil
+	// the whole value is reported.
+	Find func(value string) []string
+}
+
+// defaultLeakPatterns are checked by DetectOriginLeaks
+var defaultLeakPatterns = []LeakPattern{
+	{Header: "x-backend-server", Reason: "backend server disclosed"},
+	{Header: "x-origin-server", Reason: "origin server disclosed"},
+	{Header: "x-served-by", Reason: "serving host disclosed"},
+	{Header: "via", Reason: "proxy chain disclosed"},
+	{Reason: "internal IP address disclosed", Find: privateAddrs},
+}
+
+// DetectOriginLeaks returns the headers of the response that may expose
+// the origin server, in the order of the patterns, including those
+// added WithLeakPatterns
+func (m *Matcher) DetectOriginLeaks(resp Response) []Leak {
+	return detectOriginLeaks(resp, m.leakPatterns)
+}
+
+// detectOriginLeaks returns the headers of the response matching the
+// patterns
+func detectOriginLeaks(resp Response, patterns []LeakPattern) []Leak {
+	headers := NormalizeHeaders(resp.Headers)
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var leaks []Leak
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if pattern.Header != "" && name != pattern.Header {
+				continue
+			}
+			value := headers[name]
+			if pattern.Find == nil {
+				if value != "" {
+					leaks = append(leaks, Leak{Header: name, Value: value, Reason: pattern.Reason})
+				}
+				continue
+			}
+			for _, found := range pattern.Find(value) {
+				leaks = append(leaks, Leak{Header: name, Value: found, Reason: pattern.Reason})
+			}
+		}
+	}
+	return leaks
+}
+
+// privateAddrs returns the private, loopback and link-local IP
+// addresses found in a header value
+func privateAddrs(value string) []string {
+	var found []string
+	for _, token := range strings.FieldsFunc(value, isAddrSeparator) {
+		addr, err := netip.ParseAddr(token)
+		if err != nil {
+			addrPort, err := netip.ParseAddrPort(token)
+			if err != nil {
+				continue
+			}
+			addr = addrPort.Addr()
+		}
+		if addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+			found = append(found, addr.String())
+		}
+	}
+	return found
+}
+
+// isAddrSeparator reports whether r separates addresses in a header value
+func isAddrSeparator(r rune) bool {
+	return strings.ContainsRune(" ,;=\"()", r)
+}
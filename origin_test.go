@@ -0,0 +1,55 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectOriginLeaks(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    []Leak
+	}{
+		{
+			name:    "private IP in forwarded for",
+			headers: map[string]string{"X-Forwarded-For": "203.0.113.7, 10.0.12.4"},
+			want:    []Leak{{Header: "x-forwarded-for", Value: "10.0.12.4", Reason: "internal IP address disclosed"}},
+		},
+		{
+			name:    "private IP with port",
+			headers: map[string]string{"Forwarded": "for=192.168.1.20:8080"},
+			want:    []Leak{{Header: "forwarded", Value: "192.168.1.20", Reason: "internal IP address disclosed"}},
+		},
+		{
+			name:    "backend server",
+			headers: map[string]string{"X-Backend-Server": "app-03.internal"},
+			want:    []Leak{{Header: "x-backend-server", Value: "app-03.internal", Reason: "backend server disclosed"}},
+		},
+		{
+			name:    "public addresses only",
+			headers: map[string]string{"X-Forwarded-For": "203.0.113.7", "Server": "cloudflare"},
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, matcher.DetectOriginLeaks(Response{Headers: tt.headers}))
+		})
+	}
+}
+
+func TestWithLeakPatterns(t *testing.T) {
+	matcher, err := NewMatcher("", WithLeakPatterns(LeakPattern{Header: "x-origin-host", Reason: "origin host disclosed"}))
+	require.NoError(t, err)
+
+	resp := Response{Headers: map[string]string{"X-Origin-Host": "origin.example.com"}}
+	require.Equal(t, []Leak{{Header: "x-origin-host", Value: "origin.example.com", Reason: "origin host disclosed"}}, matcher.DetectOriginLeaks(resp))
+	defaults, err := NewMatcher("")
+	require.NoError(t, err)
+	require.Empty(t, defaults.DetectOriginLeaks(resp))
+}
@@ -0,0 +1,73 @@
+package cleanhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// maxSnippetLength bounds the length of the line shown in parse errors
+const maxSnippetLength = 80
+
+// ParseError is returned when a rules document cannot be parsed. It
+// locates the error in the document to help rule authors fix it.
+type ParseError struct {
+	Line    int
+	Column  int
+	Snippet string
+	Err     error
+
+	offset int64  // offset of the error in the JSON document
+	format string // format of the document, JSON unless set
+}
+
+func (e *ParseError) Error() string {
+	format := e.format
+	if format == "" {
+		format = "JSON"
+	}
+	return fmt.Sprintf("parsing rules %s: line %d, column %d: %v: %s", format, e.Line, e.Column, e.Err, e.Snippet)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// jsonParseError locates a JSON decoding error in the data. The offset
+// is used for errors that do not carry their own, and ignored if negative.
+func jsonParseError(data []byte, err error, offset int64) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	if offset < 0 || offset > int64(len(data)) {
+		return fmt.Errorf("parsing rules JSON: %w", err)
+	}
+
+	line, column, snippet := locate(data, int(offset))
+	return &ParseError{Line: line, Column: column, Snippet: snippet, Err: err, offset: offset}
+}
+
+// locate returns the 1-based line and column of the offset, and the
+// trimmed content of that line
+func locate(data []byte, offset int) (line, column int, snippet string) {
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	column = offset - lineStart + 1
+
+	lineEnd := bytes.IndexByte(data[lineStart:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(data) - lineStart
+	}
+	content := bytes.TrimSpace(data[lineStart : lineStart+lineEnd])
+	if len(content) > maxSnippetLength {
+		content = append(content[:maxSnippetLength:maxSnippetLength], "..."...)
+	}
+	return line, column, string(content)
+}
@@ -0,0 +1,70 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrorLocation(t *testing.T) {
+	rules := []byte(`{
+  "services": {
+    "vendor": {
+      "http_status_code": "403"
+      "http_title": "Blocked"
+    }
+  }
+}`)
+
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	err = matcher.AddRules(rules)
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	require.Equal(t, 5, parseErr.Line)
+	require.Equal(t, `"http_title": "Blocked"`, parseErr.Snippet)
+	require.Contains(t, err.Error(), "line 5")
+
+	typeErr := matcher.AddRules([]byte("{\"services\": {\n\"vendor\": {\"http_body\": \"not a list\"}}}"))
+	require.ErrorAs(t, typeErr, &parseErr)
+	require.Equal(t, 2, parseErr.Line)
+}
+
+func TestParseErrorStrict(t *testing.T) {
+	matcher, err := NewMatcher("", WithStrict(true))
+	require.NoError(t, err)
+
+	err = matcher.AddRules([]byte("{\"services\": {\n  \"vendor\": {\"http_status_code\": 403}}}"))
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	require.Equal(t, 2, parseErr.Line)
+}
+
+func TestParseErrorWithRefs(t *testing.T) {
+	rules := []byte(`{
+  "definitions": {"cf": {"http_header": {"Server": "cloudflare"}}},
+  "services": {
+    "cloudflare": {"$ref": "cf", "http_status_code": "503"},
+    "vendor": {"$ref": "cf", "http_body": "not a list"}
+  }
+}`)
+
+	for _, strict := range []bool{false, true} {
+		matcher, err := NewMatcher("", WithStrict(strict))
+		require.NoError(t, err)
+
+		err = matcher.AddRules(rules)
+		var parseErr *ParseError
+		require.ErrorAs(t, err, &parseErr)
+		require.Equal(t, 5, parseErr.Line)
+		require.Equal(t, `"vendor": {"$ref": "cf", "http_body": "not a list"}`, parseErr.Snippet)
+	}
+
+	matcher, err := NewMatcher("", WithStrict(true))
+	require.NoError(t, err)
+	err = matcher.AddRules([]byte("{\"definitions\": {},\n\"services\": {\"vendor\": {\"$ref\": \"cf\",\n\"http_bodyy\": []}}}"))
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	require.Equal(t, 3, parseErr.Line)
+}
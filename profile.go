@@ -0,0 +1,88 @@
+package cleanhttp
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// RuleStats contains the evaluation statistics of a single rule
+type RuleStats struct {
+	Evaluations int64
+	Matches     int64
+	// EvalTime is the total time spent evaluating the rule, including
+	// its regex time
+	EvalTime  time.Duration
+	RegexTime time.Duration
+}
+
+// evalRule matches a rule against the response, recording statistics
+// for the provider when profiling is enabled
+func (m *Matcher) evalRule(provider string, resp *input, rule Rule) bool {
+	if !m.profiling {
+		return m.matchRule(resp, rule)
+	}
+
+	var regexTime time.Duration
+	resp.regexTime = &regexTime
+	start := time.Now()
+	matched := m.matchRule(resp, rule)
+	evalTime := time.Since(start)
+	resp.regexTime = nil
+
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+
+	stats, ok := m.stats[provider]
+	if !ok {
+		stats = &RuleStats{}
+		m.stats[provider] = stats
+	}
+	stats.Evaluations++
+	if matched {
+		stats.Matches++
+	}
+	stats.EvalTime += evalTime
+	stats.RegexTime += regexTime
+	return matched
+}
+
+// Profile returns a snapshot of the per-provider evaluation statistics
+// collected since the matcher was created. It is empty unless the
+// matcher was created with profiling enabled.
+func (m *Matcher) Profile() map[string]RuleStats {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+
+	profile := make(map[string]RuleStats, len(m.stats))
+	for provider, stats := range m.stats {
+		profile[provider] = *stats
+	}
+	return profile
+}
+
+// FalsePositiveCandidates returns the providers whose rules matched at
+// least the given fraction of the responses they were evaluated
+// against, most matching first. Rules matching most traffic are likely
+// too generic. It relies on the statistics collected when profiling is
+// enabled, and returns nothing otherwise.
+func (m *Matcher) FalsePositiveCandidates(threshold float64) []string {
+	rates := make(map[string]float64)
+	for provider, stats := range m.Profile() {
+		if stats.Evaluations == 0 {
+			continue
+		}
+		if rate := float64(stats.Matches) / float64(stats.Evaluations); rate >= threshold {
+			rates[provider] = rate
+		}
+	}
+
+	candidates := make([]string, 0, len(rates))
+	for provider := range rates {
+		candidates = append(candidates, provider)
+	}
+	slices.SortFunc(candidates, func(a, b string) int {
+		return cmp.Or(cmp.Compare(rates[b], rates[a]), cmp.Compare(a, b))
+	})
+	return candidates
+}
@@ -0,0 +1,67 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfile(t *testing.T) {
+	matcher, err := NewMatcher("", WithProfiling(true))
+	require.NoError(t, err)
+
+	resp := Response{
+		StatusCode: 400,
+		Title:      "Invalid URL",
+		Body:       "The requested URL \"[no URL]\", is invalid.",
+		Headers:    map[string]string{"server": "AkamaiGHost"},
+	}
+	for i := 0; i < 3; i++ {
+		matcher.Match(resp)
+	}
+
+	profile := matcher.Profile()
+	for provider, rule := range matcher.rules {
+		if optInOnly(rule.Tags) {
+			require.NotContains(t, profile, provider, "opt-in rules should not be evaluated")
+		} else {
+			require.Contains(t, profile, provider)
+		}
+	}
+	require.Equal(t, int64(3), profile["akamai"].Evaluations)
+	require.Equal(t, int64(3), profile["akamai"].Matches)
+	require.Positive(t, profile["akamai"].RegexTime)
+	require.GreaterOrEqual(t, profile["akamai"].EvalTime, profile["akamai"].RegexTime)
+	require.Equal(t, int64(3), profile["cloudflare"].Evaluations)
+	require.Zero(t, profile["cloudflare"].Matches)
+}
+
+func TestProfileDisabled(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	matcher.Match(Response{StatusCode: 200})
+	require.Empty(t, matcher.Profile())
+}
+
+func TestFalsePositiveCandidates(t *testing.T) {
+	matcher, err := NewMatcher("", WithProfiling(true))
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("too_generic", RuleJSON{HTTPHeaderPresent: []string{"Content-Type"}}))
+
+	for i := 0; i < 100; i++ {
+		resp := Response{StatusCode: 200, Headers: map[string]string{"Content-Type": "text/html", "Server": "nginx"}}
+		if i%10 == 0 {
+			resp = Response{StatusCode: 503, Headers: map[string]string{"Server": "cloudflare"}, Body: "error code: 1020"}
+		}
+		matcher.Match(resp)
+	}
+
+	require.Equal(t, []string{"too_generic"}, matcher.FalsePositiveCandidates(0.5))
+	require.Equal(t, []string{"too_generic", "cloudflare"}, matcher.FalsePositiveCandidates(0.1))
+
+	disabled, err := NewMatcher("")
+	require.NoError(t, err)
+	disabled.Match(Response{StatusCode: 200})
+	require.Empty(t, disabled.FalsePositiveCandidates(0))
+}
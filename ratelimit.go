@@ -0,0 +1,30 @@
+package cleanhttp
+
+import "slices"
+
+// MatchRateLimit reports whether the response is a rate-limiting
+// response, along with the provider responsible when it can be
+// identified: the parent of the matching rate-limit rule. Rules without
+// a parent, such as generic_rate_limit, identify no provider. When
+// several providers match, the first in name order is reported.
+// Rate-limit rules are not part of Match unless the rate-limit category
+// is enabled.
+func (m *Matcher) MatchRateLimit(resp Response) (bool, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matches := m.matchCategory(resp, CategoryRateLimit)
+	if len(matches) == 0 {
+		return false, ""
+	}
+	var providers []string
+	for _, match := range matches {
+		if parent := m.rules[match].Parent; parent != "" {
+			providers = append(providers, m.alias(parent))
+		}
+	}
+	if len(providers) == 0 {
+		return true, ""
+	}
+	return true, slices.Min(providers)
+}
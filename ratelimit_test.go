@@ -0,0 +1,120 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchRateLimit(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		response     Response
+		wantLimited  bool
+		wantProvider string
+	}{
+		{
+			name: "cloudflare 1015",
+			response: Response{
+				StatusCode: 429,
+				Headers:    map[string]string{"Server": "cloudflare", "Retry-After": "60"},
+				Body:       "<h1>Error 1015</h1><p>You are being rate limited</p>",
+			},
+			wantLimited:  true,
+			wantProvider: "cloudflare",
+		},
+		{
+			name: "akamai",
+			response: Response{
+				StatusCode: 429,
+				Headers:    map[string]string{"Server": "AkamaiGHost"},
+				Body:       "<HTML><HEAD><TITLE>Too Many Requests</TITLE></HEAD></HTML>",
+			},
+			wantLimited:  true,
+			wantProvider: "akamai",
+		},
+		{
+			name: "unknown provider with retry-after",
+			response: Response{
+				StatusCode: 429,
+				Headers:    map[string]string{"Server": "nginx", "Retry-After": "30"},
+			},
+			wantLimited: true,
+		},
+		{
+			name: "not rate limited",
+			response: Response{
+				StatusCode: 503,
+				Headers:    map[string]string{"Server": "cloudflare"},
+				Body:       "error code: 1020",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limited, provider := matcher.MatchRateLimit(tt.response)
+			require.Equal(t, tt.wantLimited, limited)
+			require.Equal(t, tt.wantProvider, provider)
+			for _, match := range matcher.Match(tt.response) {
+				require.NotContains(t, matcher.rules[match].Tags, "rate-limit", "rate-limit rules are opt-in")
+			}
+		})
+	}
+}
+
+func TestMatchRateLimitOrder(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"b_rate_limit": {"parent": "b", "http_status_code": "429", "tags": ["rate-limit"]},
+		"a_rate_limit": {"parent": "a", "http_status_code": "429", "tags": ["rate-limit"]}
+	}}`), nil)
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		limited, provider := matcher.MatchRateLimit(Response{StatusCode: 429})
+		require.True(t, limited)
+		require.Equal(t, "a", provider)
+	}
+}
+
+func TestMatchRateLimitProvider(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"edge_throttle": {"parent": "edgeguard", "http_status_code": "429", "http_header": {"Server": "edgeguard"}, "tags": ["rate-limit"]},
+		"throttled_rate_limit": {"http_status_code": "429", "http_header": {"Server": "throttled"}, "tags": ["rate-limit"]},
+		"edgeguard_rate_limit": {"parent": "edgeguard", "http_status_code": "503", "http_header": {"Server": "edgeguard"}, "tags": ["waf"]}
+	}}`), []Option{WithAliases(map[string]string{"edgeguard": "edge-guard"})})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		response     Response
+		wantLimited  bool
+		wantProvider string
+	}{
+		{
+			name:         "parent of a rule without the suffix",
+			response:     Response{StatusCode: 429, Headers: map[string]string{"Server": "edgeguard"}},
+			wantLimited:  true,
+			wantProvider: "edge-guard",
+		},
+		{
+			name:        "rule without parent",
+			response:    Response{StatusCode: 429, Headers: map[string]string{"Server": "throttled"}},
+			wantLimited: true,
+		},
+		{
+			name:     "suffix without the tag",
+			response: Response{StatusCode: 503, Headers: map[string]string{"Server": "edgeguard"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limited, provider := matcher.MatchRateLimit(tt.response)
+			require.Equal(t, tt.wantLimited, limited)
+			require.Equal(t, tt.wantProvider, provider)
+		})
+	}
+}
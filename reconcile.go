@@ -0,0 +1,106 @@
+package cleanhttp
+
+import (
+	"slices"
+	"strings"
+)
+
+// defaultProviderAliases maps provider names used by other tools, such
+// as the organizations reported by cdncheck, to the provider names of
+// the rules. Keys are lowercased.
+var defaultProviderAliases = map[string]string{
+	"cloudflare, inc.":          "cloudflare",
+	"akamai technologies":       "akamai",
+	"akamai technologies, inc.": "akamai",
+	"amazon cloudfront":         "cloudfront",
+	"fastly, inc.":              "fastly",
+	"imperva":                   "incapsula",
+	"imperva incapsula":         "incapsula",
+}
+
+// ReconciledResult is a provider reported by HTTP matching, IP-based
+// detection or both
+type ReconciledResult struct {
+	Provider string `json:"provider"`
+	// HTTP and IP report which of the detections found the provider
+	HTTP bool `json:"http"`
+	IP   bool `json:"ip"`
+	// Agree is set when both detections found the provider
+	Agree bool `json:"agree"`
+	// HTTPMatches and IPMatches hold the original names that were
+	// reconciled to the provider
+	HTTPMatches []string `json:"http_matches,omitempty"`
+	IPMatches   []string `json:"ip_matches,omitempty"`
+}
+
+// Reconcile correlates the providers matched on the HTTP response with
+// the providers detected from the IP address, e.g. by cdncheck. Names
+// of other tools are normalized to the rule names, e.g. Cloudflare, Inc.
+// to cloudflare, and rule variants such as cloudflare_redirection are
+// attributed to their provider. Names added WithProviderAliases and the
+// vendors of WithPriorities are normalized too. Results are sorted by
+// provider.
+func (m *Matcher) Reconcile(httpMatches []string, ipMatches []string) []ReconciledResult {
+	return reconcile(httpMatches, ipMatches, m.providerAliases, m.priorities)
+}
+
+// reconcile correlates the matches, normalizing names through the
+// aliases and the prioritized vendors
+func reconcile(httpMatches []string, ipMatches []string, aliases map[string]string, priorities map[string]int) []ReconciledResult {
+	byProvider := make(map[string]*ReconciledResult)
+	result := func(name string) *ReconciledResult {
+		provider := canonicalProvider(name, aliases, priorities)
+		if byProvider[provider] == nil {
+			byProvider[provider] = &ReconciledResult{Provider: provider}
+		}
+		return byProvider[provider]
+	}
+	for _, name := range httpMatches {
+		r := result(name)
+		r.HTTP = true
+		r.HTTPMatches = append(r.HTTPMatches, name)
+	}
+	for _, name := range ipMatches {
+		r := result(name)
+		r.IP = true
+		r.IPMatches = append(r.IPMatches, name)
+	}
+
+	results := make([]ReconciledResult, 0, len(byProvider))
+	for _, r := range byProvider {
+		r.Agree = r.HTTP && r.IP
+		results = append(results, *r)
+	}
+	slices.SortFunc(results, func(a, b ReconciledResult) int {
+		return strings.Compare(a.Provider, b.Provider)
+	})
+	return results
+}
+
+// canonicalProvider normalizes a provider name. Aliased names are
+// replaced by their alias, and names prefixed by a known provider and an
+// underscore are attributed to that provider.
+func canonicalProvider(name string, aliases map[string]string, priorities map[string]int) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := aliases[name]; ok {
+		return alias
+	}
+	if base, _, found := strings.Cut(name, "_"); found && knownProvider(base, aliases, priorities) {
+		return base
+	}
+	return name
+}
+
+// knownProvider reports whether the name is the target of an alias or
+// a prioritized vendor
+func knownProvider(name string, aliases map[string]string, priorities map[string]int) bool {
+	if _, ok := priorities[name]; ok {
+		return true
+	}
+	for _, alias := range aliases {
+		if alias == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcile(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	results := matcher.Reconcile([]string{"cloudflare", "cloudflare_redirection", "datadome"}, []string{"Cloudflare, Inc.", "Akamai Technologies"})
+	require.Equal(t, []ReconciledResult{
+		{Provider: "akamai", IP: true, IPMatches: []string{"Akamai Technologies"}},
+		{
+			Provider:    "cloudflare",
+			HTTP:        true,
+			IP:          true,
+			Agree:       true,
+			HTTPMatches: []string{"cloudflare", "cloudflare_redirection"},
+			IPMatches:   []string{"Cloudflare, Inc."},
+		},
+		{Provider: "datadome", HTTP: true, HTTPMatches: []string{"datadome"}},
+	}, results)
+
+	require.Empty(t, matcher.Reconcile(nil, nil))
+}
+
+func TestWithProviderAliases(t *testing.T) {
+	matcher, err := NewMatcher("", WithProviderAliases(map[string]string{"Bunny.net": "bunnycdn"}))
+	require.NoError(t, err)
+
+	require.Equal(t, []ReconciledResult{{
+		Provider:    "bunnycdn",
+		HTTP:        true,
+		IP:          true,
+		Agree:       true,
+		HTTPMatches: []string{"bunnycdn"},
+		IPMatches:   []string{"Bunny.net"},
+	}}, matcher.Reconcile([]string{"bunnycdn"}, []string{"Bunny.net"}))
+	defaults, err := NewMatcher("")
+	require.NoError(t, err)
+	require.Len(t, defaults.Reconcile([]string{"bunnycdn"}, []string{"Bunny.net"}), 2)
+}
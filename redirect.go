@@ -0,0 +1,161 @@
+package cleanhttp
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CheckRedirect represents redirect checking configuration
+type CheckRedirect struct {
+	SourcePorts  []int `json:"source_ports,omitempty"`
+	TargetPorts  []int `json:"target_ports,omitempty"`
+	MinRedirects int   `json:"min_redirects,omitempty"`
+	MaxRedirects int   `json:"max_redirects,omitempty"`
+	Loop         bool  `json:"loop,omitempty"`
+	// StatusCodes restricts the redirect status codes, e.g. to the
+	// method preserving 307 and 308
+	StatusCodes []int `json:"redirect_status_codes,omitempty"`
+}
+
+// matchRedirectRule checks if a response matches redirect rules
+func matchRedirectRule(resp Response, redirectRule CheckRedirect) bool {
+	if len(redirectRule.StatusCodes) > 0 && !slices.Contains(redirectRule.StatusCodes, resp.StatusCode) {
+		return false
+	}
+	hops := len(resp.RedirectChain)
+	if redirectRule.MinRedirects > 0 && hops < redirectRule.MinRedirects {
+		return false
+	}
+	if redirectRule.MaxRedirects > 0 && hops > redirectRule.MaxRedirects {
+		return false
+	}
+	if redirectRule.Loop && !hasRedirectLoop(resp) {
+		return false
+	}
+	if len(redirectRule.SourcePorts) == 0 && len(redirectRule.TargetPorts) == 0 {
+		return true
+	}
+	return matchRedirectPorts(resp, redirectRule)
+}
+
+// matchRedirectPorts checks that the request was made to one of the
+// source ports and redirected to the root of the same host on one of
+// the target ports
+func matchRedirectPorts(resp Response, redirectRule CheckRedirect) bool {
+	parsedOriginalURL, err := url.Parse(requestURL(resp))
+	if err != nil {
+		return false
+	}
+	originalPort := getPortFromURL(parsedOriginalURL)
+
+	if !slices.Contains(redirectRule.SourcePorts, originalPort) {
+		return false
+	}
+
+	location, exists := resp.Headers["location"]
+	if !exists {
+		location, exists = refreshURL(resp.Headers["refresh"])
+	}
+	if !exists && len(resp.RedirectChain) > 0 {
+		location, exists = resp.RedirectChain[len(resp.RedirectChain)-1], true
+	}
+	if !exists {
+		return false
+	}
+
+	parsedLocation, err := url.Parse(location)
+	if err != nil {
+		return false
+	}
+
+	if !parsedLocation.IsAbs() {
+		parsedLocation.Scheme = parsedOriginalURL.Scheme
+		parsedLocation.Host = parsedOriginalURL.Host
+	}
+
+	// Only redirects to the root of the same host are considered
+	if parsedLocation.Hostname() != parsedOriginalURL.Hostname() {
+		return false
+	}
+	if parsedLocation.Path != "" && parsedLocation.Path != "/" {
+		return false
+	}
+
+	targetPort := getPortFromURL(parsedLocation)
+	return slices.Contains(redirectRule.TargetPorts, targetPort)
+}
+
+// refreshURL returns the target of a Refresh header of the form
+// "N; url=target", also accepting a comma separator, a case-insensitive
+// url key and a quoted target
+func refreshURL(refresh string) (string, bool) {
+	_, target, found := strings.Cut(refresh, ";")
+	if !found {
+		_, target, found = strings.Cut(refresh, ",")
+	}
+	if !found {
+		return "", false
+	}
+	key, target, found := strings.Cut(strings.TrimSpace(target), "=")
+	if !found || !strings.EqualFold(strings.TrimSpace(key), "url") {
+		return "", false
+	}
+	target = strings.Trim(strings.TrimSpace(target), `'"`)
+	return target, target != ""
+}
+
+// requestURL returns the URL the response was requested from
+func requestURL(resp Response) string {
+	if resp.RequestURL != "" {
+		return resp.RequestURL
+	}
+	return resp.Headers["x-original-request-url"]
+}
+
+// hasRedirectLoop reports whether a URL repeats in the redirect chain,
+// resolving relative redirects against the previous hop
+func hasRedirectLoop(resp Response) bool {
+	base, err := url.Parse(requestURL(resp))
+	if err != nil {
+		return false
+	}
+
+	seen := make(map[string]struct{}, len(resp.RedirectChain)+1)
+	if base.String() != "" {
+		seen[base.String()] = struct{}{}
+	}
+	for _, hop := range resp.RedirectChain {
+		parsed, err := url.Parse(hop)
+		if err != nil {
+			return false
+		}
+		base = base.ResolveReference(parsed)
+		key := base.String()
+		if _, ok := seen[key]; ok {
+			return true
+		}
+		seen[key] = struct{}{}
+	}
+	return false
+}
+
+// getPortFromURL extracts port from URL, returning default ports for schemes if not specified
+func getPortFromURL(u *url.URL) int {
+	port := u.Port()
+	if port != "" {
+		if p, err := strconv.Atoi(port); err == nil {
+			return p
+		}
+	}
+
+	switch u.Scheme {
+	case "https", "wss":
+		return 443
+	case "http", "ws":
+		return 80
+	default:
+		return 0
+	}
+}
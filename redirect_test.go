@@ -0,0 +1,130 @@
+package cleanhttp
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectChain(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("redirect_hops", RuleJSON{
+		CheckRedirect: &CheckRedirect{MinRedirects: 3, MaxRedirects: 5},
+	}))
+	require.NoError(t, matcher.AddRule("redirect_loop", RuleJSON{
+		CheckRedirect: &CheckRedirect{Loop: true},
+	}))
+
+	threeHops := Response{
+		StatusCode: 200,
+		RequestURL: "http://example.com/",
+		RedirectChain: []string{
+			"https://example.com/",
+			"https://www.example.com/",
+			"https://www.example.com/home",
+		},
+	}
+	require.Equal(t, []string{"redirect_hops"}, matcher.Match(threeHops))
+
+	detailed := matcher.MatchDetailed(threeHops)
+	require.Len(t, detailed.Results, 1)
+	require.False(t, detailed.RedirectLoop)
+
+	loop := Response{
+		StatusCode:    302,
+		RequestURL:    "https://example.com/login",
+		RedirectChain: []string{"/sso", "https://example.com/login"},
+		Headers:       map[string]string{"Location": "/sso"},
+	}
+	require.Equal(t, []string{"redirect_loop"}, matcher.Match(loop))
+
+	detailed = matcher.MatchDetailed(loop)
+	require.Len(t, detailed.Results, 1)
+	require.True(t, detailed.RedirectLoop)
+
+	recorded := Response{
+		StatusCode:    200,
+		Headers:       map[string]string{"X-Original-Request-URL": "https://example.com/login"},
+		RedirectChain: []string{"/sso", "/login"},
+	}
+	require.True(t, matcher.MatchDetailed(recorded).RedirectLoop)
+
+	single := Response{StatusCode: 301, RequestURL: "http://example.com/", RedirectChain: []string{"https://example.com/"}}
+	require.Empty(t, matcher.Match(single))
+}
+
+func TestRedirectStatusCodes(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("preserving_redirect", RuleJSON{
+		CheckRedirect: &CheckRedirect{
+			StatusCodes: []int{307, 308},
+			SourcePorts: []int{80},
+			TargetPorts: []int{443},
+		},
+	}))
+
+	tests := []struct {
+		status int
+		want   []string
+	}{
+		{status: 308, want: []string{"preserving_redirect"}},
+		{status: 307, want: []string{"preserving_redirect"}},
+		{status: 302, want: nil},
+		{status: 301, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.status), func(t *testing.T) {
+			resp := Response{
+				StatusCode: tt.status,
+				RequestURL: "http://example.com/",
+				Headers:    map[string]string{"Location": "https://example.com/"},
+			}
+			require.Equal(t, tt.want, matcher.Match(resp))
+		})
+	}
+
+	err = matcher.AddRule("invalid", RuleJSON{CheckRedirect: &CheckRedirect{StatusCodes: []int{200}}})
+	require.ErrorContains(t, err, "invalid redirect status code 200")
+}
+
+func TestRedirectPortsFromChain(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	resp := Response{
+		StatusCode:    301,
+		RequestURL:    "http://example.com:8080/",
+		RedirectChain: []string{"https://example.com/"},
+		Headers:       map[string]string{"Server": "cloudflare"},
+	}
+	require.Equal(t, []string{"cloudflare_redirection"}, matcher.Match(resp))
+}
+
+func TestRedirectPortsFromRefresh(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		refresh string
+		want    []string
+	}{
+		{name: "url key", refresh: "0; url=https://example.com/", want: []string{"cloudflare_redirection"}},
+		{name: "uppercase quoted", refresh: "5;URL='https://example.com'", want: []string{"cloudflare_redirection"}},
+		{name: "non root target", refresh: "0; url=https://example.com/login", want: nil},
+		{name: "delay only", refresh: "30", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := Response{
+				StatusCode: 301,
+				RequestURL: "https://example.com:2053/",
+				Headers:    map[string]string{"Server": "cloudflare", "Refresh": tt.refresh},
+			}
+			require.Equal(t, tt.want, matcher.Match(resp))
+		})
+	}
+}
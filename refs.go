@@ -0,0 +1,127 @@
+package cleanhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// rulesDocument is a rules document as written, before its references
+// are expanded
+type rulesDocument struct {
+	Version     int                 `json:"version,omitempty"`
+	Definitions map[string]RuleJSON `json:"definitions,omitempty"`
+	Services    map[string]RuleJSON `json:"services"`
+}
+
+// hasRefs reports whether the document uses definitions
+func (doc rulesDocument) hasRefs() bool {
+	if len(doc.Definitions) > 0 {
+		return true
+	}
+	for _, rule := range doc.Services {
+		if len(rule.Ref) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveRefs expands the $ref keys of the services in a rules document
+// with the condition blocks of its top-level definitions section, and
+// removes that section. A rule referencing definitions inherits their
+// keys, later references overriding earlier ones and the rule's own
+// keys overriding both. Documents that are not valid JSON are returned
+// unchanged so that decoding reports the error.
+func resolveRefs(data []byte) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return data, nil
+	}
+	var definitions, services map[string]map[string]json.RawMessage
+	if raw, ok := doc["definitions"]; ok {
+		if err := json.Unmarshal(raw, &definitions); err != nil {
+			return data, nil
+		}
+		delete(doc, "definitions")
+	}
+	if err := json.Unmarshal(doc["services"], &services); err != nil {
+		return data, nil
+	}
+
+	r := refResolver{definitions: definitions, resolved: make(map[string]map[string]json.RawMessage)}
+	for provider, rule := range services {
+		resolved, err := r.resolve(rule, nil)
+		if err != nil {
+			return nil, &RuleError{Provider: provider, Err: err}
+		}
+		services[provider] = resolved
+	}
+
+	encoded, err := json.Marshal(services)
+	if err != nil {
+		return nil, fmt.Errorf("encoding resolved rules: %w", err)
+	}
+	doc["services"] = encoded
+	return json.Marshal(doc)
+}
+
+// refResolver resolves references to definitions, caching the
+// definitions already resolved
+type refResolver struct {
+	definitions map[string]map[string]json.RawMessage
+	resolved    map[string]map[string]json.RawMessage
+}
+
+// resolve returns the block with its references expanded. path holds
+// the definitions being resolved, to detect cycles.
+func (r *refResolver) resolve(block map[string]json.RawMessage, path []string) (map[string]json.RawMessage, error) {
+	raw, ok := block["$ref"]
+	if !ok {
+		return block, nil
+	}
+	var refs HeaderPatterns
+	if err := json.Unmarshal(raw, &refs); err != nil {
+		return nil, fmt.Errorf("$ref must be a string or an array of strings")
+	}
+
+	merged := make(map[string]json.RawMessage)
+	for _, ref := range refs {
+		definition, err := r.definition(ref, path)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range definition {
+			merged[key] = value
+		}
+	}
+	for key, value := range block {
+		if key != "$ref" {
+			merged[key] = value
+		}
+	}
+	return merged, nil
+}
+
+// definition returns the resolved definition with the given name
+func (r *refResolver) definition(name string, path []string) (map[string]json.RawMessage, error) {
+	if resolved, ok := r.resolved[name]; ok {
+		return resolved, nil
+	}
+	for i, visiting := range path {
+		if visiting == name {
+			return nil, fmt.Errorf("cyclic reference %s", strings.Join(append(path[i:], name), " -> "))
+		}
+	}
+	definition, ok := r.definitions[name]
+	if !ok {
+		return nil, fmt.Errorf("undefined definition %q", name)
+	}
+
+	resolved, err := r.resolve(definition, append(path, name))
+	if err != nil {
+		return nil, err
+	}
+	r.resolved[name] = resolved
+	return resolved, nil
+}
@@ -0,0 +1,74 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefinitionRefs(t *testing.T) {
+	rules := []byte(`{
+		"definitions": {
+			"akamai_block_body": {"http_body": ["Reference&#32;&#35;"], "http_status_code": "403"},
+			"akamai_server": {"$ref": "akamai_block_body", "http_header": {"Server": "AkamaiGHost"}}
+		},
+		"services": {
+			"akamai": {"$ref": "akamai_server"},
+			"akamai_edgesuite": {"$ref": ["akamai_block_body"], "http_title": "Access Denied"}
+		}
+	}`)
+
+	for _, strict := range []bool{false, true} {
+		matcher, err := NewMatcher("", WithStrict(strict))
+		require.NoError(t, err)
+		require.NoError(t, matcher.AddRules(rules))
+
+		body := "<html>You don't have permission. Reference&#32;&#35;18.1234</html>"
+		got := matcher.Match(Response{StatusCode: 403, Headers: map[string]string{"Server": "AkamaiGHost"}, Body: body})
+		require.ElementsMatch(t, []string{"akamai"}, got)
+
+		got = matcher.Match(Response{StatusCode: 403, Title: "Access Denied", Body: body})
+		require.ElementsMatch(t, []string{"akamai_edgesuite"}, got)
+
+		got = matcher.Match(Response{StatusCode: 200, Title: "Access Denied", Body: body})
+		require.Empty(t, got, "status code is inherited from the definition")
+	}
+}
+
+func TestDefinitionRefErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		want  string
+	}{
+		{
+			name:  "undefined",
+			rules: `{"services": {"vendor": {"$ref": "missing"}}}`,
+			want:  `undefined definition "missing"`,
+		},
+		{
+			name: "cyclic",
+			rules: `{"definitions": {"a": {"$ref": "b"}, "b": {"$ref": "a"}},
+				"services": {"vendor": {"$ref": "a"}}}`,
+			want: "cyclic reference a -> b -> a",
+		},
+		{
+			name: "nested",
+			rules: `{"definitions": {"a": {"http_status_code": "403"}},
+				"services": {"vendor": {"match_groups": [{"$ref": "a"}]}}}`,
+			want: "$ref is only supported at the top level",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher, err := NewMatcher("")
+			require.NoError(t, err)
+			err = matcher.AddRules([]byte(tt.rules))
+			require.ErrorContains(t, err, tt.want)
+
+			var ruleErr *RuleError
+			require.ErrorAs(t, err, &ruleErr)
+			require.Equal(t, "vendor", ruleErr.Provider)
+		})
+	}
+}
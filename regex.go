@@ -0,0 +1,100 @@
+package cleanhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strings"
+)
+
+// maxRegexInstructions is the maximum size of the compiled program
+// for a rule regex. Patterns above it are rejected at compile time.
+const maxRegexInstructions = 5000
+
+// supportedRegexFlags are the flags allowed in a structured regex
+const supportedRegexFlags = "ims"
+
+// RegexPattern is a rule regex with optional flags. In JSON it is
+// either a plain pattern string or an object with pattern and flags.
+type RegexPattern struct {
+	Pattern string `json:"pattern"`
+	Flags   string `json:"flags,omitempty"`
+}
+
+// UnmarshalJSON decodes a regex from a string or a structured object
+func (r *RegexPattern) UnmarshalJSON(data []byte) error {
+	var pattern string
+	if err := json.Unmarshal(data, &pattern); err == nil {
+		*r = RegexPattern{Pattern: pattern}
+		return nil
+	}
+
+	type structured RegexPattern
+	var s structured
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("regex must be a string or an object with pattern and flags: %w", err)
+	}
+	*r = RegexPattern(s)
+	return nil
+}
+
+// MarshalJSON encodes the regex as a plain string when it has no flags
+func (r RegexPattern) MarshalJSON() ([]byte, error) {
+	if r.Flags == "" {
+		return json.Marshal(r.Pattern)
+	}
+	type structured RegexPattern
+	return json.Marshal(structured(r))
+}
+
+// compile translates the flags to their inline form and compiles the regex
+func (r RegexPattern) compile() (*regexp.Regexp, error) {
+	if r.Flags == "" {
+		return compileRegex(r.Pattern)
+	}
+	for _, flag := range r.Flags {
+		if !strings.ContainsRune(supportedRegexFlags, flag) {
+			return nil, fmt.Errorf("unsupported regex flag %q", flag)
+		}
+	}
+	return compileRegex("(?" + r.Flags + ")" + r.Pattern)
+}
+
+// compileRegex compiles a rule regex, rejecting patterns whose
+// compiled program is too large to be evaluated cheaply
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := syntax.Compile(parsed.Simplify())
+	if err != nil {
+		return nil, err
+	}
+	if len(prog.Inst) > maxRegexInstructions {
+		return nil, fmt.Errorf("pattern too complex (%d instructions, max %d)", len(prog.Inst), maxRegexInstructions)
+	}
+	return regexp.Compile(pattern)
+}
+
+// endSensitive reports whether a match of the regex in a prefix of a
+// text may not be a match in the whole text, because the regex asserts
+// the end of the text or of a line, or a word boundary
+func endSensitive(re *regexp.Regexp) bool {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return true
+	}
+	return hasEndAssertion(parsed)
+}
+
+// hasEndAssertion walks the regex tree for end and boundary assertions
+func hasEndAssertion(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpEndLine, syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return true
+	}
+	return slices.ContainsFunc(re.Sub, hasEndAssertion)
+}
@@ -0,0 +1,54 @@
+package cleanhttp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegexPatternFlags(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	err = matcher.AddRules([]byte(`{"services": {
+		"inline": {"http_body_regex": ["(?im)^access denied$"], "http_title_regex": "(?i)blocked"},
+		"structured": {"http_body_regex": [{"pattern": "^access denied$", "flags": "im"}], "http_title_regex": {"pattern": "blocked", "flags": "i"}}
+	}}`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		response Response
+		want     []string
+	}{
+		{
+			name:     "flags applied",
+			response: Response{Title: "Request BLOCKED", Body: "<h1>\nACCESS DENIED\n</h1>"},
+			want:     []string{"inline", "structured"},
+		},
+		{
+			name:     "no match",
+			response: Response{Title: "Request BLOCKED", Body: "access denied for you"},
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ElementsMatch(t, tt.want, matcher.Match(tt.response))
+		})
+	}
+}
+
+func TestRegexPatternJSON(t *testing.T) {
+	var patterns []RegexPattern
+	require.NoError(t, json.Unmarshal([]byte(`["plain", {"pattern": "flagged", "flags": "s"}]`), &patterns))
+	require.Equal(t, []RegexPattern{{Pattern: "plain"}, {Pattern: "flagged", Flags: "s"}}, patterns)
+
+	data, err := json.Marshal(patterns)
+	require.NoError(t, err)
+	require.JSONEq(t, `["plain", {"pattern": "flagged", "flags": "s"}]`, string(data))
+
+	_, err = RegexPattern{Pattern: "x", Flags: "U"}.compile()
+	require.Error(t, err)
+}
@@ -0,0 +1,101 @@
+package cleanhttp
+
+// ResponseMeta describes a response whose body has not been read yet.
+// Its Body, Title, Titles and Trailers are ignored by MatchEarly.
+type ResponseMeta = Response
+
+// DefaultBodyScanLimit is the number of body bytes a BodyScanner keeps
+// when no limit is given
+const DefaultBodyScanLimit = 1 << 20
+
+// BodyScanner accumulates a response body written to it in chunks, so
+// it can be teed from a streamed body and matched once the stream ends.
+// Only the first limit bytes are kept. Chunks are joined in a single
+// buffer, so patterns split across writes still match as long as they
+// fall within the limit.
+type BodyScanner struct {
+	matcher   *Matcher
+	limit     int
+	buf       []byte
+	truncated bool
+}
+
+// NewBodyScanner returns a BodyScanner keeping up to limit bytes of the
+// body, or DefaultBodyScanLimit bytes if limit is not positive
+func (m *Matcher) NewBodyScanner(limit int) *BodyScanner {
+	if limit <= 0 {
+		limit = DefaultBodyScanLimit
+	}
+	return &BodyScanner{matcher: m, limit: limit}
+}
+
+// Write appends p to the buffered body. Bytes past the limit are
+// discarded, but are still reported as written so that a tee never
+// fails because of the scanner.
+func (s *BodyScanner) Write(p []byte) (int, error) {
+	remaining := s.limit - len(s.buf)
+	if len(p) > remaining {
+		s.buf = append(s.buf, p[:remaining]...)
+		s.truncated = true
+		return len(p), nil
+	}
+	s.buf = append(s.buf, p...)
+	return len(p), nil
+}
+
+// Truncated reports whether part of the body was discarded
+func (s *BodyScanner) Truncated() bool {
+	return s.truncated
+}
+
+// Match returns the names of WAF/CDN providers that match the response
+// described by meta with the buffered body. The title is extracted from
+// the body when meta has none. When part of the body was discarded, the
+// buffer is matched as a prefix, as by MatchEarly.
+func (s *BodyScanner) Match(meta Response) []string {
+	meta.Body = string(s.buf)
+	if meta.Title == "" {
+		meta.Title = s.matcher.extractTitle(meta.Body)
+	}
+	if !s.truncated {
+		return s.matcher.Match(meta)
+	}
+
+	s.matcher.mu.RLock()
+	defer s.matcher.mu.RUnlock()
+
+	in := s.matcher.newInput(meta, nil)
+	in.partial = true
+	matches, _ := s.matcher.matchInput(in)
+	return s.matcher.resultNames(matches)
+}
+
+// Reset clears the buffered body so the scanner can be reused
+func (s *BodyScanner) Reset() {
+	s.buf = s.buf[:0]
+	s.truncated = false
+}
+
+// MatchEarly matches a response using only the first bytes of its body,
+// so callers can stop reading once a challenge or block page has been
+// recognized. Only conditions that cannot be invalidated by the rest of
+// the body take part: body patterns found in the prefix, body lines
+// ending in it, and the title when its closing tag is in the prefix.
+// Body length bounds use the Content-Length header rather than the
+// prefix. Rules with trailer or JSON conditions, or body regexes
+// asserting the end of the text, a line end or a word boundary, never
+// match. The boolean reports whether any rule matched.
+func (m *Matcher) MatchEarly(meta ResponseMeta, firstChunk []byte) ([]string, bool) {
+	meta.Body = string(firstChunk)
+	meta.Title = m.extractTitle(meta.Body)
+	meta.Titles = nil
+	meta.Trailers = nil
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	in := m.newInput(meta, nil)
+	in.partial = true
+	matches, _ := m.matchInput(in)
+	return m.resultNames(matches), len(matches) > 0
+}
@@ -0,0 +1,139 @@
+package cleanhttp
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyScanner(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	err = matcher.AddRule("cloudflare", RuleJSON{HTTPBody: []string{"cf-error-details"}})
+	require.NoError(t, err)
+
+	scanner := matcher.NewBodyScanner(0)
+	body := `<html><div id="cf-error-details">blocked</div></html>`
+	for i := 0; i < len(body); i += 3 {
+		_, err := scanner.Write([]byte(body[i:min(i+3, len(body))]))
+		require.NoError(t, err)
+	}
+	require.False(t, scanner.Truncated())
+	require.Equal(t, []string{"cloudflare"}, scanner.Match(Response{StatusCode: 403}))
+
+	scanner.Reset()
+	require.Empty(t, scanner.Match(Response{StatusCode: 403}))
+}
+
+func TestBodyScannerLimit(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	err = matcher.AddRule("cloudflare", RuleJSON{HTTPBody: []string{"cf-error-details"}})
+	require.NoError(t, err)
+
+	scanner := matcher.NewBodyScanner(16)
+	body := strings.Repeat("x", 16) + "cf-error-details"
+	n, err := io.Copy(io.Discard, io.TeeReader(strings.NewReader(body), scanner))
+	require.NoError(t, err)
+	require.Equal(t, int64(len(body)), n)
+	require.True(t, scanner.Truncated())
+	require.Empty(t, scanner.Match(Response{}))
+}
+
+func TestBodyScannerTruncatedLength(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("short_page", RuleJSON{HTTPBodyLengthMax: 100}))
+
+	scanner := matcher.NewBodyScanner(50)
+	_, err = scanner.Write([]byte(strings.Repeat("x", 5000)))
+	require.NoError(t, err)
+	require.True(t, scanner.Truncated())
+	require.Empty(t, scanner.Match(Response{StatusCode: 200, Headers: map[string]string{"Content-Length": "5000"}}))
+	require.Empty(t, scanner.Match(Response{StatusCode: 200}), "length is unknown without Content-Length")
+
+	scanner.Reset()
+	_, err = scanner.Write([]byte(strings.Repeat("x", 40)))
+	require.NoError(t, err)
+	require.Equal(t, []string{"short_page"}, scanner.Match(Response{StatusCode: 200}))
+}
+
+func TestMatchEarly(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("cloudflare_challenge", RuleJSON{
+		HTTPStatusCode: "403",
+		HTTPTitle:      "Just a moment...",
+		HTTPBody:       []string{"/cdn-cgi/challenge-platform/"},
+	}))
+	require.NoError(t, matcher.AddRule("challenge_footer", RuleJSON{HTTPBody: []string{"Performance & security by Cloudflare"}}))
+	require.NoError(t, matcher.AddRule("challenge_trailer", RuleJSON{HTTPTrailer: map[string]string{"X-Challenge": "1"}}))
+
+	page := `<!DOCTYPE html><html lang="en-US"><head><title>Just a moment...</title>` +
+		`<meta http-equiv="Content-Type" content="text/html; charset=UTF-8">` +
+		`<script src="/cdn-cgi/challenge-platform/h/b/orchestrate/chl_page/v1"></script></head>` +
+		strings.Repeat("<div>", 200) + `Performance & security by Cloudflare</html>`
+	meta := ResponseMeta{StatusCode: 403, Headers: map[string]string{"Server": "cloudflare"}}
+
+	got, ok := matcher.MatchEarly(meta, []byte(page[:512]))
+	require.True(t, ok)
+	require.Equal(t, []string{"cloudflare_challenge"}, got)
+
+	got, ok = matcher.MatchEarly(meta, []byte(page[:40]))
+	require.False(t, ok, "title is incomplete in the prefix")
+	require.Empty(t, got)
+}
+
+func TestMatchEarlyPrefixSafe(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"blocked_line": {"http_body_line_contains": ["blocked"]},
+		"denied_end": {"http_body_regex": ["denied\\s*$"]},
+		"denied_word": {"http_body_regex": ["\\bdenied\\b"]},
+		"denied": {"http_body_regex": ["access denied"]},
+		"json_error": {"http_json": {"error": "blocked"}},
+		"short_body": {"http_body_length_max": 100, "http_body": ["x"]}
+	}}`), nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		prefix string
+		body   string
+		want   []string
+	}{
+		{
+			name:   "unterminated last line",
+			prefix: "x\nblocked",
+			body:   "x\nblocked by policy\n",
+			want:   nil,
+		},
+		{
+			name:   "complete line",
+			prefix: "x\nblocked\nmore",
+			body:   "x\nblocked\nmore text\n",
+			want:   []string{"blocked_line"},
+		},
+		{
+			name:   "end anchored regex",
+			prefix: "access denied",
+			body:   "access deniedness is not a word",
+			want:   []string{"denied"},
+		},
+		{
+			name:   "json and length without content length",
+			prefix: `{"error": "blocked", "x": 1}`,
+			body:   `{"error": "blocked", "x": 1, "padding": "` + strings.Repeat("x", 200) + `"}`,
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := matcher.MatchEarly(ResponseMeta{StatusCode: 200}, []byte(tt.prefix))
+			require.ElementsMatch(t, tt.want, got)
+			// Every early match must hold for the full body
+			require.Subset(t, matcher.Match(Response{StatusCode: 200, Body: tt.body}), got)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package cleanhttp
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// securityHeaders are the lowercased response headers counted as
+// security headers
+var securityHeaders = []string{
+	"content-security-policy",
+	"content-security-policy-report-only",
+	"cross-origin-embedder-policy",
+	"cross-origin-opener-policy",
+	"cross-origin-resource-policy",
+	"permissions-policy",
+	"referrer-policy",
+	"strict-transport-security",
+	"x-content-type-options",
+	"x-frame-options",
+	"x-xss-protection",
+}
+
+// SecurityHeaderProfile summarizes the security headers of a response
+type SecurityHeaderProfile struct {
+	// Present lists the lowercased security headers present, sorted
+	Present []string `json:"present,omitempty"`
+	// HSTSMaxAge is the Strict-Transport-Security max-age in seconds
+	HSTSMaxAge int `json:"hsts_max_age,omitempty"`
+	// HSTSIncludeSubDomains and HSTSPreload report the HSTS directives
+	HSTSIncludeSubDomains bool `json:"hsts_include_subdomains,omitempty"`
+	HSTSPreload           bool `json:"hsts_preload,omitempty"`
+	// CSP maps the Content-Security-Policy directives to their sources
+	CSP map[string][]string `json:"csp,omitempty"`
+	// FrameOptions is the uppercased X-Frame-Options value
+	FrameOptions string `json:"frame_options,omitempty"`
+}
+
+// SecurityHeaders returns the security headers profile of a response.
+// HSTS and CSP values are parsed minimally, unknown directives are kept
+// for CSP and ignored for HSTS.
+func SecurityHeaders(resp Response) SecurityHeaderProfile {
+	headers := NormalizeHeaders(resp.Headers)
+
+	var profile SecurityHeaderProfile
+	profile.Present = presentSecurityHeaders(headers)
+	if value, ok := headers["strict-transport-security"]; ok {
+		for _, directive := range strings.Split(value, ";") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			switch strings.ToLower(name) {
+			case "max-age":
+				profile.HSTSMaxAge, _ = strconv.Atoi(strings.Trim(arg, `"`))
+			case "includesubdomains":
+				profile.HSTSIncludeSubDomains = true
+			case "preload":
+				profile.HSTSPreload = true
+			}
+		}
+	}
+	if value, ok := headers["content-security-policy"]; ok {
+		profile.CSP = make(map[string][]string)
+		for _, directive := range strings.Split(value, ";") {
+			fields := strings.Fields(directive)
+			if len(fields) == 0 {
+				continue
+			}
+			profile.CSP[strings.ToLower(fields[0])] = fields[1:]
+		}
+	}
+	profile.FrameOptions = strings.ToUpper(strings.TrimSpace(headers["x-frame-options"]))
+	return profile
+}
+
+// presentSecurityHeaders returns the security headers present in the
+// lowercased headers
+func presentSecurityHeaders(headers map[string]string) []string {
+	var present []string
+	for _, header := range securityHeaders {
+		if _, ok := headers[header]; ok {
+			present = append(present, header)
+		}
+	}
+	return slices.Clip(present)
+}
@@ -0,0 +1,41 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	profile := SecurityHeaders(Response{Headers: map[string]string{
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains; preload",
+		"Content-Security-Policy":   "default-src 'self'; script-src 'self' https://cdn.example.com; upgrade-insecure-requests",
+		"X-Frame-Options":           "sameorigin",
+		"Server":                    "cloudflare",
+	}})
+
+	require.Equal(t, []string{"content-security-policy", "strict-transport-security", "x-frame-options"}, profile.Present)
+	require.Equal(t, 31536000, profile.HSTSMaxAge)
+	require.True(t, profile.HSTSIncludeSubDomains)
+	require.True(t, profile.HSTSPreload)
+	require.Equal(t, map[string][]string{
+		"default-src":               {"'self'"},
+		"script-src":                {"'self'", "https://cdn.example.com"},
+		"upgrade-insecure-requests": {},
+	}, profile.CSP)
+	require.Equal(t, "SAMEORIGIN", profile.FrameOptions)
+
+	require.Equal(t, SecurityHeaderProfile{}, SecurityHeaders(Response{Headers: map[string]string{"Server": "nginx"}}))
+}
+
+func TestMinSecurityHeaders(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("hardened_edge", RuleJSON{MinSecurityHeaders: 2}))
+
+	require.Equal(t, []string{"hardened_edge"}, matcher.Match(Response{Headers: map[string]string{
+		"Strict-Transport-Security": "max-age=300",
+		"X-Content-Type-Options":    "nosniff",
+	}}))
+	require.Empty(t, matcher.Match(Response{Headers: map[string]string{"X-Content-Type-Options": "nosniff"}}))
+}
@@ -0,0 +1,44 @@
+package cleanhttp
+
+import "strings"
+
+// ServerParser parses Server header values the generic parser gets
+// wrong, reporting false for values it does not recognize
+type ServerParser func(value string) (product, version, extra string, ok bool)
+
+// ServerInfo splits the Server header of the response into the product
+// name, its version and any extra information. Values of the common
+// form "product/version (comment) more" are parsed heuristically:
+//
+//	nginx/1.18.0 (Ubuntu)   -> nginx, 1.18.0, Ubuntu
+//	ECS (dcb/7F83)          -> ECS, "", dcb/7F83
+//	cloudflare              -> cloudflare, "", ""
+//
+// The extra information is the rest of the value, with the parentheses
+// removed when it is a single comment. Parsers added WithServerParsers
+// are tried first, in order.
+func (m *Matcher) ServerInfo(resp Response) (product string, version string, extra string) {
+	return serverInfo(resp, m.serverParsers)
+}
+
+// serverInfo splits the Server header, trying the parsers before the
+// generic one
+func serverInfo(resp Response, parsers []ServerParser) (product string, version string, extra string) {
+	value := strings.TrimSpace(NormalizeHeaders(resp.Headers)["server"])
+	if value == "" {
+		return "", "", ""
+	}
+	for _, parse := range parsers {
+		if product, version, extra, ok := parse(value); ok {
+			return product, version, extra
+		}
+	}
+
+	token, rest, _ := strings.Cut(value, " ")
+	product, version, _ = strings.Cut(token, "/")
+	extra = strings.TrimSpace(rest)
+	if strings.HasPrefix(extra, "(") && strings.Index(extra, ")") == len(extra)-1 {
+		extra = extra[1 : len(extra)-1]
+	}
+	return product, version, extra
+}
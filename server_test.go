@@ -0,0 +1,47 @@
+package cleanhttp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerInfo(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		server  string
+		product string
+		version string
+		extra   string
+	}{
+		{server: "cloudflare", product: "cloudflare"},
+		{server: "cloudflare-nginx", product: "cloudflare-nginx"},
+		{server: "ECS (dcb/7F83)", product: "ECS", extra: "dcb/7F83"},
+		{server: "nginx/1.18.0 (Ubuntu)", product: "nginx", version: "1.18.0", extra: "Ubuntu"},
+		{server: "Apache/2.4.41 (Unix) OpenSSL/1.1.1", product: "Apache", version: "2.4.41", extra: "(Unix) OpenSSL/1.1.1"},
+		{server: "Microsoft-IIS/10.0", product: "Microsoft-IIS", version: "10.0"},
+		{server: "", product: ""},
+	}
+	for _, tt := range tests {
+		product, version, extra := matcher.ServerInfo(Response{Headers: map[string]string{"Server": tt.server}})
+		require.Equal(t, tt.product, product, tt.server)
+		require.Equal(t, tt.version, version, tt.server)
+		require.Equal(t, tt.extra, extra, tt.server)
+	}
+}
+
+func TestServerParsers(t *testing.T) {
+	matcher, err := NewMatcher("", WithServerParsers(func(value string) (string, string, string, bool) {
+		product, extra, ok := strings.Cut(value, "-")
+		return product, "", extra, ok && product == "cloudflare"
+	}))
+	require.NoError(t, err)
+
+	product, version, extra := matcher.ServerInfo(Response{Headers: map[string]string{"Server": "cloudflare-nginx"}})
+	require.Equal(t, "cloudflare", product)
+	require.Empty(t, version)
+	require.Equal(t, "nginx", extra)
+}
@@ -0,0 +1,554 @@
+package cleanhttp
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Signal names a group of rule conditions that is weighted as a whole
+// in rules using a minimum score
+const (
+	SignalStatus      = "status"
+	SignalMethod      = "method"
+	SignalHeader      = "header"
+	SignalContentType = "content_type"
+	SignalTrailer     = "trailer"
+	SignalBody        = "body"
+	SignalTitle       = "title"
+	SignalProtocol    = "protocol"
+	SignalTLS         = "tls"
+	SignalNetwork     = "network"
+	SignalRedirect    = "redirect"
+	SignalGroup       = "group"
+)
+
+// signals lists the signals in evaluation order
+var signals = []string{
+	SignalStatus,
+	SignalMethod,
+	SignalHeader,
+	SignalContentType,
+	SignalTrailer,
+	SignalBody,
+	SignalTitle,
+	SignalProtocol,
+	SignalTLS,
+	SignalNetwork,
+	SignalRedirect,
+	SignalGroup,
+}
+
+// validateWeights checks the signal weights and minimum score of a rule
+func validateWeights(weights map[string]float64, minScore float64) error {
+	for signal, weight := range weights {
+		if !slices.Contains(signals, signal) {
+			return fmt.Errorf("unknown weighted signal %q", signal)
+		}
+		if weight < 0 {
+			return fmt.Errorf("negative weight %v for signal %q", weight, signal)
+		}
+	}
+	if minScore < 0 {
+		return fmt.Errorf("negative min_score %v", minScore)
+	}
+	if minScore > 0 && len(weights) == 0 {
+		return fmt.Errorf("min_score %v requires weights", minScore)
+	}
+	return nil
+}
+
+// countConditions returns how many of the conditions of the rule the
+// response meets, and how many conditions the rule has. Match groups
+// count the conditions of the first group that matches, or a single
+// failed condition when none does, and on_404 those of the error page
+// rule.
+func (m *Matcher) countConditions(resp *input, rule Rule) (matched, total int) {
+	count := func(groupMatched, groupTotal int) {
+		matched += groupMatched
+		total += groupTotal
+	}
+	for _, signal := range signals {
+		if signal == SignalGroup {
+			continue
+		}
+		m.evalSignal(resp, rule, signal, func(condition Condition) {
+			if condition.Met() {
+				count(1, 1)
+			} else {
+				count(0, 1)
+			}
+		})
+	}
+	if len(rule.Groups) > 0 {
+		if i := slices.IndexFunc(rule.Groups, func(group Rule) bool { return m.matchRule(resp, group) }); i >= 0 {
+			count(m.countConditions(resp, rule.Groups[i]))
+		} else {
+			count(0, 1)
+		}
+	}
+	if rule.On404 != nil {
+		if resp.errorPage != nil {
+			count(m.countConditions(resp.errorPage, *rule.On404))
+		} else {
+			count(0, 1)
+		}
+	}
+	return matched, total
+}
+
+// signalEval evaluates the conditions of one signal. Without a
+// recorder, evaluation stops at the first condition that does not hold;
+// with one, every condition is evaluated and recorded.
+type signalEval struct {
+	signal string
+	record func(Condition)
+	ok     bool
+}
+
+// check notes the outcome of a condition, given the result it has when
+// it does not hold, and reports whether evaluation goes on. describe is
+// only called when recording.
+func (e *signalEval) check(ok bool, failure string, describe func() string) bool {
+	if !ok {
+		e.ok = false
+	}
+	if e.record == nil {
+		return ok
+	}
+	result := ResultOK
+	if !ok {
+		result = failure
+	}
+	e.record(Condition{Signal: e.signal, Description: describe(), Result: result})
+	return true
+}
+
+// eachKey calls check with the keys of the map until it returns false.
+// Keys are sorted when recording, so that conditions are reported in a
+// stable order.
+func eachKey[V any](e *signalEval, values map[string]V, check func(key string) bool) bool {
+	if e.record == nil {
+		for key := range values {
+			if !check(key) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, key := range sortedKeys(values) {
+		check(key)
+	}
+	return true
+}
+
+// evalSignal evaluates the conditions of a rule belonging to a signal.
+// checked is false when the rule has no conditions for the signal. When
+// record is set, it receives the outcome of every condition.
+func (m *Matcher) evalSignal(resp *input, rule Rule, signal string, record func(Condition)) (checked, ok bool) {
+	headers, body, titles := resp.fields(rule)
+	e := &signalEval{signal: signal, record: record, ok: true}
+
+	switch signal {
+	case SignalStatus:
+		if !rule.HasStatus && rule.StatusReason == "" {
+			return false, false
+		}
+		if rule.HasStatus && !e.check(rule.statusInRange(resp.StatusCode), ResultFailed, func() string {
+			if rule.StatusMin == rule.StatusMax {
+				return fmt.Sprintf("status %d is %d", resp.StatusCode, rule.StatusMin)
+			}
+			return fmt.Sprintf("status %d in range %d-%d", resp.StatusCode, rule.StatusMin, rule.StatusMax)
+		}) {
+			return true, false
+		}
+		if rule.StatusReason != "" {
+			e.check(strings.Contains(strings.ToLower(resp.StatusReason), rule.StatusReason), ResultFailed, func() string {
+				return fmt.Sprintf("status reason contains %q", rule.StatusReason)
+			})
+		}
+		return true, e.ok
+
+	case SignalMethod:
+		if len(rule.Methods) == 0 {
+			return false, false
+		}
+		e.check(slices.Contains(rule.Methods, strings.ToUpper(resp.Method)), ResultFailed, func() string {
+			return fmt.Sprintf("method %s in %s", resp.Method, strings.Join(rule.Methods, ", "))
+		})
+		return true, e.ok
+
+	case SignalHeader:
+		if len(rule.Headers) == 0 && len(rule.HeadersPresent) == 0 && len(rule.HeaderCounts) == 0 && rule.AgeMin == 0 && rule.AgeMax == 0 && len(rule.Vary) == 0 && len(rule.Cookies) == 0 &&
+			rule.LinkRel == "" && len(rule.LinkContains) == 0 && len(rule.TechStack) == 0 && len(rule.ReflectedHeaders) == 0 && rule.MinSecurityHeaders == 0 && len(rule.RawHeaderRegex) == 0 {
+			return false, false
+		}
+		if !e.headerPatterns("header", headers, rule.Headers) {
+			return true, false
+		}
+		for _, header := range rule.HeadersPresent {
+			if !e.check(hasKey(resp.Headers, header), ResultMissing, func() string {
+				return fmt.Sprintf("header %s present", header)
+			}) {
+				return true, false
+			}
+		}
+		if !eachKey(e, rule.HeaderCounts, func(header string) bool {
+			count := len(resp.values[header])
+			return e.check(count >= rule.HeaderCounts[header], ResultFailed, func() string {
+				return fmt.Sprintf("header %s repeated %d times, at least %d", header, count, rule.HeaderCounts[header])
+			})
+		}) {
+			return true, false
+		}
+		if rule.AgeMin != 0 || rule.AgeMax != 0 {
+			age, known := resp.age()
+			failure := ResultFailed
+			if !known {
+				failure = ResultMissing
+			}
+			if !e.check(known && rule.ageInRange(age), failure, func() string {
+				if rule.AgeMax == 0 {
+					return fmt.Sprintf("age %d at least %d", age, rule.AgeMin)
+				}
+				return fmt.Sprintf("age %d in range %d-%d", age, rule.AgeMin, rule.AgeMax)
+			}) {
+				return true, false
+			}
+		}
+		if len(rule.Vary) > 0 {
+			tokens := headerTokens(resp.values["vary"])
+			if !e.check(!slices.ContainsFunc(rule.Vary, func(token string) bool { return !slices.Contains(tokens, token) }), ResultFailed, func() string {
+				return fmt.Sprintf("vary lists %s", strings.Join(rule.Vary, ", "))
+			}) {
+				return true, false
+			}
+		}
+		if len(rule.Cookies) > 0 {
+			names := cookieNames(resp.values["set-cookie"])
+			for _, cookie := range rule.Cookies {
+				if !e.check(matchCookies(names, []string{cookie}), ResultMissing, func() string {
+					return fmt.Sprintf("cookie %s set", cookie)
+				}) {
+					return true, false
+				}
+			}
+		}
+		if len(rule.TechStack) > 0 {
+			stack := techStack(resp.Headers, m.techHeaders)
+			for _, requirement := range rule.TechStack {
+				if !e.check(matchTechStack(stack, []string{requirement}), ResultMissing, func() string {
+					return fmt.Sprintf("tech stack has %s", requirement)
+				}) {
+					return true, false
+				}
+			}
+		}
+		if rule.LinkRel != "" || len(rule.LinkContains) > 0 {
+			links := parseLinks(resp.values["link"])
+			if rule.LinkRel != "" && !e.check(matchLinks(links, rule.LinkRel, nil), ResultMissing, func() string {
+				return fmt.Sprintf("link rel=%s", rule.LinkRel)
+			}) {
+				return true, false
+			}
+			for _, pattern := range rule.LinkContains {
+				if !e.check(matchLinks(links, rule.LinkRel, []string{pattern}), ResultMissing, func() string {
+					return fmt.Sprintf("link to %s", pattern)
+				}) {
+					return true, false
+				}
+			}
+		}
+		for _, header := range rule.ReflectedHeaders {
+			if !e.check(reflected(resp, header), ResultFailed, func() string {
+				return fmt.Sprintf("header %s reflected", header)
+			}) {
+				return true, false
+			}
+		}
+		if rule.MinSecurityHeaders > 0 {
+			present := len(presentSecurityHeaders(resp.Headers))
+			if !e.check(present >= rule.MinSecurityHeaders, ResultFailed, func() string {
+				return fmt.Sprintf("%d security headers, at least %d", present, rule.MinSecurityHeaders)
+			}) {
+				return true, false
+			}
+		}
+		for _, re := range rule.RawHeaderRegex {
+			if !e.check(m.matchRegex(resp, re, resp.RawHeaders), ResultFailed, func() string {
+				return fmt.Sprintf("raw headers match %q", re.String())
+			}) {
+				return true, false
+			}
+		}
+		return true, e.ok
+
+	case SignalContentType:
+		if len(rule.ContentTypes) == 0 {
+			return false, false
+		}
+		contentType := mediaType(resp.Headers["content-type"])
+		e.check(slices.Contains(rule.ContentTypes, contentType), ResultFailed, func() string {
+			return fmt.Sprintf("content type %q in %s", contentType, strings.Join(rule.ContentTypes, ", "))
+		})
+		return true, e.ok
+
+	case SignalTrailer:
+		if len(rule.Trailers) == 0 {
+			return false, false
+		}
+		if resp.partial {
+			e.check(false, ResultSkipped, func() string { return "trailer conditions" })
+			return true, false
+		}
+		e.headerPatterns("trailer", headerValues(resp.Trailers), rule.Trailers)
+		return true, e.ok
+
+	case SignalBody:
+		if len(rule.BodyContains) == 0 && len(rule.BodyLines) == 0 && len(rule.BodyRegex) == 0 &&
+			len(rule.JSONFields) == 0 && !rule.RequireBody && rule.BodyLengthMin == 0 && rule.BodyLengthMax == 0 {
+			return false, false
+		}
+		if rule.RequireBody && !e.check(resp.hasBody(), ResultMissing, func() string { return "body not empty" }) {
+			return true, false
+		}
+		if rule.BodyLengthMin != 0 || rule.BodyLengthMax != 0 {
+			length, known := resp.bodyLength()
+			failure := ResultFailed
+			if !known {
+				failure = ResultUnknown
+			}
+			if !e.check(known && rule.bodyLengthInRange(length), failure, func() string {
+				if rule.BodyLengthMax == 0 {
+					return fmt.Sprintf("body length %d at least %d", length, rule.BodyLengthMin)
+				}
+				return fmt.Sprintf("body length %d in range %d-%d", length, rule.BodyLengthMin, rule.BodyLengthMax)
+			}) {
+				return true, false
+			}
+		}
+		if resp.skipBody && (len(rule.BodyContains) > 0 || len(rule.BodyLines) > 0 || len(rule.BodyRegex) > 0 || len(rule.JSONFields) > 0) {
+			e.check(false, ResultSkipped, func() string { return "body conditions" })
+			return true, false
+		}
+		if resp.partial && rule.needsFullBody {
+			e.check(false, ResultSkipped, func() string { return "body conditions needing the full body" })
+			return true, false
+		}
+		for _, pattern := range rule.BodyContains {
+			if !e.check(strings.Contains(body, pattern), ResultFailed, func() string {
+				return fmt.Sprintf("body contains %q", pattern)
+			}) {
+				return true, false
+			}
+		}
+		lines := body
+		if resp.partial {
+			// The last line of a prefix may continue past it
+			lines = body[:strings.LastIndexByte(body, '\n')+1]
+		}
+		for _, line := range rule.BodyLines {
+			if !e.check(containsLine(lines, line), ResultFailed, func() string {
+				return fmt.Sprintf("body has line %q", line)
+			}) {
+				return true, false
+			}
+		}
+		for _, re := range rule.BodyRegex {
+			if !e.check(m.matchRegex(resp, re, resp.Body), ResultFailed, func() string {
+				return fmt.Sprintf("body matches %q", re.String())
+			}) {
+				return true, false
+			}
+		}
+		eachKey(e, rule.JSONFields, func(path string) bool {
+			pattern := rule.JSONFields[path]
+			ok := resp.matchJSON(path, pattern)
+			failure := ResultFailed
+			if _, found := resp.jsonValue(path); !ok && !found {
+				failure = ResultMissing
+			}
+			return e.check(ok, failure, func() string {
+				return fmt.Sprintf("json %s contains %q", path, pattern)
+			})
+		})
+		return true, e.ok
+
+	case SignalTitle:
+		if !rule.hasTitleConditions() {
+			return false, false
+		}
+		ok := false
+		for i := range titles {
+			if ok = m.matchTitle(resp, rule, titles, i); ok {
+				break
+			}
+		}
+		if record != nil {
+			// Title conditions are reported one by one, while the signal
+			// needs a single title meeting all of them
+			if rule.TitleExact != "" {
+				e.check(slices.Contains(titles, rule.TitleExact), ResultFailed, func() string {
+					return fmt.Sprintf("title is %q", rule.TitleExact)
+				})
+			}
+			if rule.TitleRegex != nil {
+				e.check(slices.ContainsFunc(resp.titles, func(title string) bool { return m.matchRegex(resp, rule.TitleRegex, title) }), ResultFailed, func() string {
+					return fmt.Sprintf("title matches %q", rule.TitleRegex.String())
+				})
+			}
+			if rule.TitleFuzzy != nil {
+				e.check(slices.ContainsFunc(titles, rule.TitleFuzzy.match), ResultFailed, func() string {
+					return fmt.Sprintf("title within %d edits of %q", rule.TitleFuzzy.MaxDistance, rule.TitleFuzzy.Title)
+				})
+			}
+		}
+		return true, ok
+
+	case SignalProtocol:
+		if rule.ALPN == "" && len(rule.H2Settings) == 0 && !rule.ConnReset && !rule.GoAway && len(rule.FailureContains) == 0 {
+			return false, false
+		}
+		if rule.ALPN != "" && !e.check(resp.ALPN == rule.ALPN, ResultFailed, func() string {
+			return fmt.Sprintf("alpn is %q", rule.ALPN)
+		}) {
+			return true, false
+		}
+		if !eachKey(e, rule.H2Settings, func(setting string) bool {
+			value, exists := resp.H2Settings[setting]
+			return e.check(exists && value == rule.H2Settings[setting], ResultFailed, func() string {
+				return fmt.Sprintf("h2 setting %s is %d", setting, rule.H2Settings[setting])
+			})
+		}) {
+			return true, false
+		}
+		if rule.ConnReset && !e.check(resp.ConnReset, ResultFailed, func() string { return "connection reset" }) {
+			return true, false
+		}
+		if rule.GoAway && !e.check(resp.GoAway, ResultFailed, func() string { return "http/2 goaway received" }) {
+			return true, false
+		}
+		failure := strings.ToLower(resp.FailureReason)
+		for _, pattern := range rule.FailureContains {
+			if !e.check(strings.Contains(failure, pattern), ResultFailed, func() string {
+				return fmt.Sprintf("failure reason contains %q", pattern)
+			}) {
+				return true, false
+			}
+		}
+		return true, e.ok
+
+	case SignalTLS:
+		if len(rule.TLSSANs) == 0 && rule.TLSCommonName == "" {
+			return false, false
+		}
+		if rule.TLSCommonName != "" && !e.check(strings.Contains(strings.ToLower(resp.TLSCommonName), rule.TLSCommonName), ResultFailed, func() string {
+			return fmt.Sprintf("tls common name contains %q", rule.TLSCommonName)
+		}) {
+			return true, false
+		}
+		for _, pattern := range rule.TLSSANs {
+			if !e.check(matchSAN(resp.TLSSANs, pattern), ResultFailed, func() string {
+				return fmt.Sprintf("tls san contains %q", pattern)
+			}) {
+				return true, false
+			}
+		}
+		return true, e.ok
+
+	case SignalNetwork:
+		if len(rule.ASN) == 0 && rule.OrgContains == "" {
+			return false, false
+		}
+		if len(rule.ASN) > 0 && !e.check(slices.Contains(rule.ASN, resp.ASN), ResultFailed, func() string {
+			asns := make([]string, len(rule.ASN))
+			for i, asn := range rule.ASN {
+				asns[i] = strconv.Itoa(asn)
+			}
+			return fmt.Sprintf("asn %d in %s", resp.ASN, strings.Join(asns, ", "))
+		}) {
+			return true, false
+		}
+		if rule.OrgContains != "" {
+			e.check(strings.Contains(strings.ToLower(resp.ASNOrg), rule.OrgContains), ResultFailed, func() string {
+				return fmt.Sprintf("org contains %q", rule.OrgContains)
+			})
+		}
+		return true, e.ok
+
+	case SignalRedirect:
+		if rule.RedirectCheck == nil {
+			return false, false
+		}
+		e.check(matchRedirectRule(resp.Response, *rule.RedirectCheck), ResultFailed, func() string { return "redirect conditions" })
+		return true, e.ok
+
+	case SignalGroup:
+		if len(rule.Groups) == 0 {
+			return false, false
+		}
+		e.check(slices.ContainsFunc(rule.Groups, func(group Rule) bool {
+			return m.matchRule(resp, group)
+		}), ResultFailed, func() string {
+			return fmt.Sprintf("one of %d match groups matches", len(rule.Groups))
+		})
+		return true, e.ok
+	}
+	return false, false
+}
+
+// headerPatterns checks that, for every header, one of its values
+// contains one of the patterns listed for it, a missing header failing
+// with ResultMissing
+func (e *signalEval) headerPatterns(kind string, values, patterns map[string][]string) bool {
+	return eachKey(e, patterns, func(header string) bool {
+		alternatives := patterns[header]
+		failure := ResultFailed
+		if !hasKey(values, header) {
+			failure = ResultMissing
+		}
+		return e.check(headerContains(values[header], alternatives), failure, func() string {
+			return fmt.Sprintf("%s %s contains %s", kind, header, strings.Join(alternatives, " or "))
+		})
+	})
+}
+
+// hasKey reports whether the map has the key
+func hasKey[V any](values map[string]V, key string) bool {
+	_, ok := values[key]
+	return ok
+}
+
+// matchSAN reports whether one of the certificate subject alternative
+// names contains the lowercased pattern
+func matchSAN(sans []string, pattern string) bool {
+	return slices.ContainsFunc(sans, func(san string) bool {
+		return strings.Contains(strings.ToLower(san), pattern)
+	})
+}
+
+// reflected reports whether the value the request sent for a header is
+// echoed back in a response header of the same name
+func reflected(resp *input, header string) bool {
+	sent, ok := resp.RequestHeaders[header]
+	if !ok || sent == "" {
+		return false
+	}
+	return slices.ContainsFunc(resp.values[header], func(value string) bool {
+		return strings.Contains(value, sent)
+	})
+}
+
+// containsLine reports whether the body has a line equal to the given
+// one once surrounding whitespace is trimmed
+func containsLine(body, line string) bool {
+	for body != "" {
+		var current string
+		current, body, _ = strings.Cut(body, "\n")
+		if strings.TrimSpace(current) == line {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeightedRule(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	err = matcher.AddRule("cloudflare", RuleJSON{
+		HTTPHeader: map[string]HeaderPatterns{"Server": {"cloudflare"}},
+		HTTPTitle:  "Attention Required! | Cloudflare",
+		HTTPBody:   []string{"cf-ray"},
+		Weights:    map[string]float64{SignalHeader: 2, SignalTitle: 3, SignalBody: 1},
+		MinScore:   4,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		resp Response
+		want []string
+	}{
+		{
+			name: "all signals",
+			resp: Response{Headers: map[string]string{"server": "cloudflare"}, Title: "Attention Required! | Cloudflare", Body: "cf-ray"},
+			want: []string{"cloudflare"},
+		},
+		{
+			name: "header and title",
+			resp: Response{Headers: map[string]string{"server": "cloudflare"}, Title: "Attention Required! | Cloudflare"},
+			want: []string{"cloudflare"},
+		},
+		{
+			name: "title and body",
+			resp: Response{Title: "Attention Required! | Cloudflare", Body: "cf-ray"},
+			want: []string{"cloudflare"},
+		},
+		{
+			name: "header and body",
+			resp: Response{Headers: map[string]string{"server": "cloudflare"}, Body: "cf-ray"},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, matcher.Match(tt.resp))
+		})
+	}
+}
+
+func TestWeightsWithoutMinScore(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	err = matcher.AddRule("cloudflare", RuleJSON{
+		HTTPHeader: map[string]HeaderPatterns{"Server": {"cloudflare"}},
+		HTTPBody:   []string{"cf-ray"},
+		Weights:    map[string]float64{SignalHeader: 2, SignalBody: 1},
+	})
+	require.NoError(t, err)
+
+	require.Empty(t, matcher.Match(Response{Headers: map[string]string{"server": "cloudflare"}}))
+	require.Equal(t, []string{"cloudflare"}, matcher.Match(Response{Headers: map[string]string{"server": "cloudflare"}, Body: "cf-ray"}))
+}
+
+func TestInvalidWeights(t *testing.T) {
+	tests := []struct {
+		name string
+		rule RuleJSON
+	}{
+		{name: "unknown signal", rule: RuleJSON{Weights: map[string]float64{"cookie": 1}, MinScore: 1}},
+		{name: "negative weight", rule: RuleJSON{Weights: map[string]float64{SignalBody: -1}, MinScore: 1}},
+		{name: "min score without weights", rule: RuleJSON{MinScore: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher, err := NewMatcher("")
+			require.NoError(t, err)
+			require.Error(t, matcher.AddRule("provider", tt.rule))
+		})
+	}
+}
+
+func TestHTTPBodyLineContains(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRule("varnish_guru", RuleJSON{HTTPBodyLine: []string{"<!-- Guru Meditation -->"}}))
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{name: "full line", body: "<html>\n  <!-- Guru Meditation -->\r\n</html>", want: []string{"varnish_guru"}},
+		{name: "last line", body: "<html></html>\n<!-- Guru Meditation -->", want: []string{"varnish_guru"}},
+		{name: "mid line", body: "<html><!-- Guru Meditation --></html>", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, matcher.Match(Response{Body: tt.body}))
+		})
+	}
+}
+
+func TestRequireBody(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"permissive": {"http_status_code": "403", "http_body_regex": ["(?i)(denied)?"], "require_body": true},
+		"loose": {"http_status_code": "403", "http_body_regex": ["(?i)(denied)?"]}
+	}}`), nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{name: "empty body", body: "", want: []string{"loose"}},
+		{name: "whitespace body", body: " \r\n", want: []string{"loose"}},
+		{name: "content", body: "Access denied", want: []string{"loose", "permissive"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matcher.Match(Response{StatusCode: 403, Body: tt.body})
+			require.ElementsMatch(t, tt.want, got)
+		})
+	}
+}
+
+func TestEvalSignalRecord(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	rule, err := compileRule(RuleJSON{
+		HTTPHeader:        map[string]HeaderPatterns{"Via": {"varnish"}, "Server": {"cloudflare"}},
+		HTTPHeaderPresent: []string{"CF-RAY"},
+		HTTPCookie:        []string{"__cf_bm"},
+	})
+	require.NoError(t, err)
+	in := matcher.newInput(Response{Headers: map[string]string{"Server": "nginx"}}, nil)
+
+	checked, ok := matcher.evalSignal(in, rule, SignalHeader, nil)
+	require.True(t, checked)
+	require.False(t, ok)
+
+	var conditions []Condition
+	checked, ok = matcher.evalSignal(in, rule, SignalHeader, func(condition Condition) {
+		conditions = append(conditions, condition)
+	})
+	require.True(t, checked)
+	require.False(t, ok)
+	require.Equal(t, []Condition{
+		{Signal: SignalHeader, Description: "header server contains cloudflare", Result: ResultFailed},
+		{Signal: SignalHeader, Description: "header via contains varnish", Result: ResultMissing},
+		{Signal: SignalHeader, Description: "header cf-ray present", Result: ResultMissing},
+		{Signal: SignalHeader, Description: "cookie __cf_bm set", Result: ResultMissing},
+	}, conditions, "every condition is recorded, not only the first failure")
+}
@@ -0,0 +1,211 @@
+package cleanhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// NewMatcherFromReader creates a Matcher from a JSON rules document read
+// from r. Rather than decoding the whole document at once, rules are
+// decoded and compiled one provider at a time, so loading holds the
+// compiled rules and a single undecoded rule instead of the document
+// and its decoded form. The matcher is the same as the one NewMatcher
+// builds from the same document.
+func NewMatcherFromReader(r io.Reader, opts ...Option) (*Matcher, error) {
+	m := emptyMatcher(opts)
+	if err := m.AddRulesFromReader(r); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AddRulesFromReader is AddRules streaming the document from r. Since
+// the document is read once, definitions referenced with $ref must
+// precede the services section. As with AddRules, no rule is added if
+// any fails to compile.
+func (m *Matcher) AddRulesFromReader(r io.Reader) error {
+	input := &lineTracker{r: r, line: 1}
+	decoder := json.NewDecoder(input)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return input.parseError(decoder, err)
+	}
+
+	resolver := &refResolver{resolved: make(map[string]map[string]json.RawMessage)}
+	compiled := make(map[string]Rule)
+	for decoder.More() {
+		input.release(decoder.InputOffset())
+		token, err := decoder.Token()
+		if err != nil {
+			return input.parseError(decoder, err)
+		}
+		switch key, _ := token.(string); key {
+		case "version":
+			var version int
+			if err := decoder.Decode(&version); err != nil {
+				return input.parseError(decoder, err)
+			}
+			if err := checkSchemaVersion(version); err != nil {
+				return err
+			}
+		case "definitions":
+			if err := decoder.Decode(&resolver.definitions); err != nil {
+				return input.parseError(decoder, err)
+			}
+		case "services":
+			if err := m.decodeServices(decoder, input, resolver, compiled); err != nil {
+				return err
+			}
+		default:
+			if m.strict {
+				return input.parseError(decoder, fmt.Errorf("json: unknown field %q", key))
+			}
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return input.parseError(decoder, err)
+			}
+		}
+	}
+	if err := expectDelim(decoder, '}'); err != nil {
+		return input.parseError(decoder, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for provider, rule := range compiled {
+		m.setRule(provider, rule)
+	}
+	m.sortRules()
+	return nil
+}
+
+// decodeServices decodes and compiles the rules of the services object
+// one provider at a time
+func (m *Matcher) decodeServices(decoder *json.Decoder, input *lineTracker, resolver *refResolver, compiled map[string]Rule) error {
+	if err := expectDelim(decoder, '{'); err != nil {
+		return input.parseError(decoder, err)
+	}
+	seen := make(map[string]struct{})
+	for decoder.More() {
+		input.release(decoder.InputOffset())
+		token, err := decoder.Token()
+		if err != nil {
+			return input.parseError(decoder, err)
+		}
+		provider, _ := token.(string)
+		if _, ok := seen[provider]; ok && m.strict {
+			return fmt.Errorf("duplicate providers in rules JSON: %s", provider)
+		}
+		seen[provider] = struct{}{}
+
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return input.parseError(decoder, err)
+		}
+		source, err := m.decodeRule(raw, resolver)
+		if err != nil {
+			return &RuleError{Provider: provider, Err: err}
+		}
+		rule, err := compileRule(source)
+		if err != nil {
+			return &RuleError{Provider: provider, Err: err}
+		}
+		compiled[provider] = rule
+	}
+	return expectDelim(decoder, '}')
+}
+
+// decodeRule decodes a single rule, expanding its references
+func (m *Matcher) decodeRule(raw json.RawMessage, resolver *refResolver) (RuleJSON, error) {
+	rule, err := m.unmarshalRule(raw)
+	if err != nil || len(rule.Ref) == 0 {
+		return rule, err
+	}
+
+	var block map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &block); err != nil {
+		return RuleJSON{}, err
+	}
+	resolved, err := resolver.resolve(block, nil)
+	if err != nil {
+		return RuleJSON{}, err
+	}
+	if raw, err = json.Marshal(resolved); err != nil {
+		return RuleJSON{}, err
+	}
+	return m.unmarshalRule(raw)
+}
+
+// unmarshalRule decodes a single rule, rejecting unknown fields in
+// strict mode
+func (m *Matcher) unmarshalRule(raw json.RawMessage) (RuleJSON, error) {
+	var rule RuleJSON
+	if !m.strict {
+		return rule, json.Unmarshal(raw, &rule)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rule); err != nil {
+		return RuleJSON{}, err
+	}
+	return rule, nil
+}
+
+// lineTracker keeps the input read by a json.Decoder from the start of
+// the line it was last released at, so that decoding errors can be
+// located by line while holding a single rule
+type lineTracker struct {
+	r      io.Reader
+	kept   []byte // input read from keptAt on
+	keptAt int64  // offset of kept, at the start of a line
+	line   int    // 1-based line of keptAt
+}
+
+func (t *lineTracker) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.kept = append(t.kept, p[:n]...)
+	return n, err
+}
+
+// release forgets the input before the line holding offset, which
+// decoding errors can no longer point into
+func (t *lineTracker) release(offset int64) {
+	before := t.kept[:offset-t.keptAt]
+	start := bytes.LastIndexByte(before, '\n') + 1
+	t.line += bytes.Count(before[:start], []byte("\n"))
+	t.keptAt += int64(start)
+	t.kept = append(t.kept[:0], t.kept[start:]...)
+}
+
+// parseError locates a decoding error like jsonParseError does for a
+// whole document, at the offset of syntax errors or else at the
+// position reached by the decoder
+func (t *lineTracker) parseError(decoder *json.Decoder, err error) error {
+	offset := decoder.InputOffset()
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+	}
+	if offset < t.keptAt || offset > t.keptAt+int64(len(t.kept)) {
+		return fmt.Errorf("parsing rules JSON at offset %d: %w", offset, err)
+	}
+
+	// Read the rest of the line for the snippet, which the decoder may
+	// not have reached yet
+	buf := make([]byte, maxSnippetLength)
+	for {
+		rest := t.kept[offset-t.keptAt:]
+		if len(rest) >= maxSnippetLength || bytes.IndexByte(rest, '\n') >= 0 {
+			break
+		}
+		if n, err := t.Read(buf); n == 0 && err != nil {
+			break
+		}
+	}
+
+	line, column, snippet := locate(t.kept, int(offset-t.keptAt))
+	return &ParseError{Line: t.line + line - 1, Column: column, Snippet: snippet, Err: err, offset: offset}
+}
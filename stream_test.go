@@ -0,0 +1,210 @@
+package cleanhttp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// largeRules returns a rules document holding copies copies of the
+// embedded rules under distinct provider names
+func largeRules(t testing.TB, copies int) []byte {
+	var doc ServicesJSON
+	require.NoError(t, json.Unmarshal(defaultRules, &doc))
+
+	services := make(map[string]RuleJSON, len(doc.Services)*copies)
+	for i := 0; i < copies; i++ {
+		for provider, rule := range doc.Services {
+			services[fmt.Sprintf("%s_%d", provider, i)] = rule
+		}
+	}
+	data, err := json.Marshal(ServicesJSON{Version: SchemaVersion, Services: services})
+	require.NoError(t, err)
+	return data
+}
+
+func TestNewMatcherFromReader(t *testing.T) {
+	data := largeRules(t, 20)
+
+	batch, err := newMatcher(data, nil)
+	require.NoError(t, err)
+	streamed, err := NewMatcherFromReader(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	require.Equal(t, batch.rules, streamed.rules)
+
+	resp := Response{StatusCode: 503, Headers: map[string]string{"Server": "cloudflare"}, Body: "error code: 1016"}
+	require.ElementsMatch(t, batch.Match(resp), streamed.Match(resp))
+	require.Contains(t, streamed.Match(resp), "cloudflare_0")
+}
+
+func TestAddRulesFromReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		strict  bool
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "references",
+			rules: `{
+				"definitions": {"cf": {"http_header": {"Server": "cloudflare"}}},
+				"services": {"cloudflare": {"$ref": "cf", "http_status_code": "503"}}
+			}`,
+			want: []string{"cloudflare"},
+		},
+		{
+			name:  "unknown top-level key",
+			rules: `{"comment": "ignored", "services": {"cloudflare": {"http_header": {"Server": "cloudflare"}}}}`,
+			want:  []string{"cloudflare"},
+		},
+		{
+			name:    "unknown top-level key strict",
+			rules:   `{"comment": "rejected", "services": {}}`,
+			strict:  true,
+			wantErr: `unknown field "comment"`,
+		},
+		{
+			name:    "unknown rule key strict",
+			rules:   `{"services": {"cloudflare": {"http_bodyy": ["error"]}}}`,
+			strict:  true,
+			wantErr: "compiling rule for cloudflare",
+		},
+		{
+			name:    "duplicate strict",
+			rules:   `{"services": {"cloudflare": {"http_status_code": "503"}, "cloudflare": {"http_status_code": "503"}}}`,
+			strict:  true,
+			wantErr: "duplicate providers in rules JSON: cloudflare",
+		},
+		{
+			name:    "newer schema",
+			rules:   `{"version": 99, "services": {}}`,
+			wantErr: "schema version 99",
+		},
+		{
+			name:    "invalid regex",
+			rules:   `{"services": {"broken": {"http_body_regex": ["("]}}}`,
+			wantErr: "compiling rule for broken",
+		},
+		{
+			name:    "truncated",
+			rules:   `{"services": {"cloudflare": {"http_status_code": "503"}`,
+			wantErr: "parsing rules JSON: line 1, column 56: unexpected end of JSON input",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matcher := emptyMatcher([]Option{WithStrict(tt.strict)})
+			err := matcher.AddRulesFromReader(strings.NewReader(tt.rules))
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				require.Empty(t, matcher.rules)
+				return
+			}
+			require.NoError(t, err)
+			resp := Response{StatusCode: 503, Headers: map[string]string{"Server": "cloudflare"}}
+			require.Equal(t, tt.want, matcher.Match(resp))
+		})
+	}
+}
+
+// BenchmarkLoadRules compares loading a large rules file at once and
+// streaming it. Besides allocations, it reports the peak heap in use
+// while loading.
+func BenchmarkLoadRules(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "rules.json")
+	require.NoError(b, os.WriteFile(path, largeRules(b, 200), 0o600))
+
+	load := map[string]func() (*Matcher, error){
+		"batch": func() (*Matcher, error) {
+			return NewMatcher(path)
+		},
+		"stream": func() (*Matcher, error) {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return NewMatcherFromReader(bufio.NewReader(f))
+		},
+	}
+	for _, name := range []string{"batch", "stream"} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			var peak uint64
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				done := make(chan struct{})
+				sampled := make(chan uint64)
+				go func() {
+					var highest uint64
+					var stats runtime.MemStats
+					for {
+						runtime.ReadMemStats(&stats)
+						highest = max(highest, stats.HeapInuse)
+						select {
+						case <-done:
+							sampled <- highest
+							return
+						case <-time.After(time.Millisecond):
+						}
+					}
+				}()
+				if _, err := load[name](); err != nil {
+					b.Fatal(err)
+				}
+				close(done)
+				peak = max(peak, <-sampled)
+			}
+			b.ReportMetric(float64(peak), "peak-heap-B")
+		})
+	}
+}
+
+func TestAddRulesFromReaderParseError(t *testing.T) {
+	var long strings.Builder
+	long.WriteString("{\n  \"services\": {\n")
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&long, "    \"vendor_%d\": {\"http_status_code\": \"403\"},\n", i)
+	}
+	long.WriteString("    \"broken\": {\"http_status_code\": \"403\" \"http_title\": \"Blocked\"}\n  }\n}")
+
+	tests := []struct {
+		name  string
+		rules string
+	}{
+		{
+			name: "syntax",
+			rules: `{
+  "services": {
+    "vendor": {
+      "http_status_code": "403"
+      "http_title": "Blocked"
+    }
+  }
+}`,
+		},
+		{name: "after many rules", rules: long.String()},
+		{name: "top level", rules: "{\"version\": 1,\n\"services\" {}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var batchErr, streamErr *ParseError
+			require.ErrorAs(t, emptyMatcher(nil).AddRules([]byte(tt.rules)), &batchErr)
+			require.ErrorAs(t, emptyMatcher(nil).AddRulesFromReader(strings.NewReader(tt.rules)), &streamErr)
+			require.Equal(t, batchErr.Line, streamErr.Line)
+			require.Equal(t, batchErr.Column, streamErr.Column)
+			require.Equal(t, batchErr.Snippet, streamErr.Snippet)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package cleanhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// decodeRules decodes a rules document and expands its references. The
+// document is decoded as written before references are expanded, so
+// that parse errors are located in it. In strict mode unknown fields
+// and providers defined more than once are rejected.
+func decodeRules(data []byte, strict bool) (ServicesJSON, error) {
+	var doc rulesDocument
+	if err := decodeDocument(data, &doc, strict); err != nil {
+		return ServicesJSON{}, err
+	}
+
+	services := doc.Services
+	if doc.hasRefs() {
+		resolved, err := resolveRefs(data)
+		if err != nil {
+			return ServicesJSON{}, err
+		}
+		var resolvedJSON ServicesJSON
+		if err := json.Unmarshal(resolved, &resolvedJSON); err != nil {
+			return ServicesJSON{}, fmt.Errorf("parsing resolved rules JSON: %w", err)
+		}
+		services = resolvedJSON.Services
+	}
+
+	if strict {
+		duplicates, err := duplicateProviders(data)
+		if err != nil {
+			return ServicesJSON{}, fmt.Errorf("parsing rules JSON: %w", err)
+		}
+		if len(duplicates) > 0 {
+			return ServicesJSON{}, fmt.Errorf("duplicate providers in rules JSON: %s", strings.Join(duplicates, ", "))
+		}
+	}
+	return ServicesJSON{Version: doc.Version, Services: services}, nil
+}
+
+// decodeDocument decodes the rules document as written, rejecting
+// unknown fields in strict mode
+func decodeDocument(data []byte, doc *rulesDocument, strict bool) error {
+	if !strict {
+		if err := json.Unmarshal(data, doc); err != nil {
+			return jsonParseError(data, err, -1)
+		}
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(doc); err != nil {
+		// Unknown keys are likely explained by rules written for a newer
+		// schema
+		var versioned struct {
+			Version int `json:"version"`
+		}
+		if json.Unmarshal(data, &versioned) == nil {
+			if versionErr := checkSchemaVersion(versioned.Version); versionErr != nil {
+				return fmt.Errorf("%w: %w", versionErr, jsonParseError(data, err, decoder.InputOffset()))
+			}
+		}
+		return jsonParseError(data, err, decoder.InputOffset())
+	}
+	return nil
+}
+
+// duplicateProviders returns the provider keys defined more than once
+// in the services object of the rules document
+func duplicateProviders(data []byte) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+
+	var duplicates []string
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if key != "services" {
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := expectDelim(decoder, '{'); err != nil {
+			return nil, err
+		}
+		seen := make(map[string]struct{})
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			provider, _ := token.(string)
+			if _, ok := seen[provider]; ok && !slices.Contains(duplicates, provider) {
+				duplicates = append(duplicates, provider)
+			}
+			seen[provider] = struct{}{}
+
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return nil, err
+			}
+		}
+		if err := expectDelim(decoder, '}'); err != nil {
+			return nil, err
+		}
+	}
+	return duplicates, nil
+}
+
+// expectDelim reads the next token and checks it is the given delimiter
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %q, got %v", delim, token)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictDuplicateProviders(t *testing.T) {
+	rules := []byte(`{"services": {
+		"vendor": {"http_status_code": "403"},
+		"other": {"http_status_code": "500"},
+		"vendor": {"http_status_code": "503"}
+	}}`)
+
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+	require.NoError(t, matcher.AddRules(rules), "duplicates are only rejected in strict mode")
+
+	strictMatcher, err := NewMatcher("", WithStrict(true))
+	require.NoError(t, err)
+	err = strictMatcher.AddRules(rules)
+	require.ErrorContains(t, err, "duplicate providers in rules JSON: vendor")
+}
+
+func TestStrictUnknownFields(t *testing.T) {
+	strictMatcher, err := NewMatcher("", WithStrict(true))
+	require.NoError(t, err)
+
+	err = strictMatcher.AddRules([]byte(`{"services": {"vendor": {"http_titel": "Blocked"}}}`))
+	require.ErrorContains(t, err, "http_titel")
+}
@@ -0,0 +1,111 @@
+package cleanhttp
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Technology is a product and version revealed by a response header
+type Technology struct {
+	// Product is the lowercased product name, e.g. php or asp.net
+	Product string `json:"product"`
+	Version string `json:"version,omitempty"`
+	// Header is the lowercased header the technology was read from
+	Header string `json:"header"`
+}
+
+// TechHeader describes a header revealing the technology stack
+type TechHeader struct {
+	// Header is the lowercased header name
+	Header string
+	// Product names the technology when the header only holds its
+	// version, as X-AspNet-Version does. When empty the value is parsed
+	// as comma separated "product/version" or "product version" items.
+	Product string
+}
+
+// defaultTechHeaders are the headers read by TechStack, in order
+var defaultTechHeaders = []TechHeader{
+	{Header: "x-powered-by"},
+	{Header: "x-aspnet-version", Product: "asp.net"},
+	{Header: "x-aspnetmvc-version", Product: "asp.net mvc"},
+	{Header: "x-generator"},
+	{Header: "x-drupal-cache", Product: "drupal"},
+}
+
+// TechStack returns the technologies revealed by the headers of the
+// response, normalized to lowercased product names with their version,
+// e.g. "X-Powered-By: PHP/8.1" gives php 8.1. A product is reported once,
+// the first header naming it taking precedence. Headers added
+// WithTechHeaders are read too.
+func (m *Matcher) TechStack(resp Response) []Technology {
+	return techStack(NormalizeHeaders(resp.Headers), m.techHeaders)
+}
+
+// techStack extracts the technologies from lowercased headers
+func techStack(headers map[string]string, techHeaders []TechHeader) []Technology {
+	var stack []Technology
+	add := func(tech Technology) {
+		if tech.Product == "" || slices.ContainsFunc(stack, func(t Technology) bool { return t.Product == tech.Product }) {
+			return
+		}
+		stack = append(stack, tech)
+	}
+	for _, techHeader := range techHeaders {
+		value, ok := headers[techHeader.Header]
+		if !ok {
+			continue
+		}
+		if techHeader.Product != "" {
+			version := strings.TrimSpace(value)
+			if !startsWithDigit(version) {
+				version = ""
+			}
+			add(Technology{Product: techHeader.Product, Version: version, Header: techHeader.Header})
+			continue
+		}
+		for _, item := range strings.Split(value, ",") {
+			product, version := parseTechItem(item)
+			add(Technology{Product: product, Version: version, Header: techHeader.Header})
+		}
+	}
+	return stack
+}
+
+// parseTechItem splits "PHP/8.1", "Drupal 10 (https://www.drupal.org)"
+// or "Express" into a lowercased product and a version
+func parseTechItem(item string) (product, version string) {
+	item = strings.TrimSpace(item)
+	if i := strings.IndexByte(item, '('); i >= 0 {
+		item = strings.TrimSpace(item[:i])
+	}
+	if name, rest, found := strings.Cut(item, "/"); found {
+		return strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(rest)
+	}
+	fields := strings.Fields(item)
+	if len(fields) > 1 && startsWithDigit(fields[len(fields)-1]) {
+		return strings.ToLower(strings.Join(fields[:len(fields)-1], " ")), fields[len(fields)-1]
+	}
+	return strings.ToLower(item), ""
+}
+
+// startsWithDigit reports whether the string starts with a digit
+func startsWithDigit(s string) bool {
+	return s != "" && unicode.IsDigit(rune(s[0]))
+}
+
+// matchTechStack checks that every requirement, a product optionally
+// followed by a slash and a version prefix such as php/8, is part of
+// the technology stack
+func matchTechStack(stack []Technology, requirements []string) bool {
+	for _, requirement := range requirements {
+		product, version, _ := strings.Cut(requirement, "/")
+		if !slices.ContainsFunc(stack, func(tech Technology) bool {
+			return tech.Product == product && strings.HasPrefix(tech.Version, version)
+		}) {
+			return false
+		}
+	}
+	return true
+}
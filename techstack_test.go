@@ -0,0 +1,78 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTechStack(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    []Technology
+	}{
+		{
+			name:    "php",
+			headers: map[string]string{"X-Powered-By": "PHP/8.1"},
+			want:    []Technology{{Product: "php", Version: "8.1", Header: "x-powered-by"}},
+		},
+		{
+			name:    "aspnet",
+			headers: map[string]string{"X-AspNet-Version": "4.0.30319", "X-Powered-By": "ASP.NET"},
+			want: []Technology{
+				{Product: "asp.net", Header: "x-powered-by"},
+			},
+		},
+		{
+			name:    "aspnet version only",
+			headers: map[string]string{"X-AspNet-Version": "4.0.30319"},
+			want:    []Technology{{Product: "asp.net", Version: "4.0.30319", Header: "x-aspnet-version"}},
+		},
+		{
+			name:    "several items and generator",
+			headers: map[string]string{"X-Powered-By": "Express, Next.js", "X-Generator": "Drupal 10 (https://www.drupal.org)"},
+			want: []Technology{
+				{Product: "express", Header: "x-powered-by"},
+				{Product: "next.js", Header: "x-powered-by"},
+				{Product: "drupal", Version: "10", Header: "x-generator"},
+			},
+		},
+		{
+			name:    "none",
+			headers: map[string]string{"Server": "nginx"},
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, matcher.TechStack(Response{Headers: tt.headers}))
+		})
+	}
+}
+
+func TestTechStackRule(t *testing.T) {
+	matcher, err := newMatcher([]byte(`{"services": {
+		"legacy_php": {"tech_stack": ["php/7"]},
+		"php_app": {"tech_stack": ["PHP"]}
+	}}`), nil)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"php_app"}, matcher.Match(Response{Headers: map[string]string{"X-Powered-By": "PHP/8.1"}}))
+	require.ElementsMatch(t, []string{"legacy_php", "php_app"}, matcher.Match(Response{Headers: map[string]string{"X-Powered-By": "PHP/7.4.33"}}))
+	require.Empty(t, matcher.Match(Response{Headers: map[string]string{"X-Powered-By": "Express"}}))
+}
+
+func TestWithTechHeaders(t *testing.T) {
+	matcher, err := NewMatcher("", WithTechHeaders(TechHeader{Header: "x-drupal-version", Product: "drupal"}))
+	require.NoError(t, err)
+
+	resp := Response{Headers: map[string]string{"X-Drupal-Version": "10.1"}}
+	require.Equal(t, []Technology{{Product: "drupal", Version: "10.1", Header: "x-drupal-version"}}, matcher.TechStack(resp))
+	defaults, err := NewMatcher("")
+	require.NoError(t, err)
+	require.Empty(t, defaults.TechStack(resp))
+}
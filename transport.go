@@ -0,0 +1,72 @@
+package cleanhttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// TransportReadLimit caps the body bytes read by WrapTransport to match
+// a response. Longer bodies are matched like MatchEarly, from their
+// first bytes only.
+const TransportReadLimit = 64 << 10
+
+// transport runs the matcher on every response of the wrapped
+// round tripper
+type transport struct {
+	next    http.RoundTripper
+	matcher *Matcher
+	onMatch func(*http.Request, []string)
+}
+
+// WrapTransport returns a round tripper matching every response of rt,
+// or of http.DefaultTransport when rt is nil, and calling onMatch with
+// the request and the matched providers when there are any. At most
+// TransportReadLimit bytes of the body are read, and they are put back
+// in front of the remaining body, so callers consume it as usual. A
+// response whose body cannot be read is returned without matching.
+func WrapTransport(rt http.RoundTripper, m *Matcher, onMatch func(*http.Request, []string)) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &transport{next: rt, matcher: m, onMatch: onMatch}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	prefix, complete, err := readPrefix(resp, TransportReadLimit)
+	if err != nil {
+		return resp, nil
+	}
+
+	var matches []string
+	meta := responseMeta(resp)
+	if complete {
+		meta.Body = string(prefix)
+		meta.Title = t.matcher.extractTitle(meta.Body)
+		meta.Trailers = flattenHeader(resp.Trailer)
+		matches = t.matcher.Match(meta)
+	} else {
+		matches, _ = t.matcher.MatchEarly(meta, prefix)
+	}
+	if len(matches) > 0 && t.onMatch != nil {
+		t.onMatch(req, matches)
+	}
+	return resp, nil
+}
+
+// readPrefix reads at most limit bytes of the body and puts them back in
+// front of the remaining body. It reports whether the whole body was
+// read.
+func readPrefix(resp *http.Response, limit int) ([]byte, bool, error) {
+	read, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(read), resp.Body), resp.Body}
+	return read[:min(len(read), limit)], len(read) <= limit, err
+}
@@ -0,0 +1,106 @@
+package cleanhttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/blocked" {
+			w.Header().Set("Server", "cloudflare")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte("error code: 1020"))
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	var matched map[string][]string
+	client := &http.Client{Transport: WrapTransport(nil, matcher, func(req *http.Request, providers []string) {
+		if matched == nil {
+			matched = make(map[string][]string)
+		}
+		matched[req.URL.Path] = providers
+	})}
+
+	for _, path := range []string{"/blocked", "/"} {
+		resp, err := client.Get(server.URL + path)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.NotEmpty(t, body, "body should be restored for the caller")
+	}
+	require.Equal(t, map[string][]string{"/blocked": {"cloudflare"}}, matched)
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestWrapTransportBoundedRead(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	page := "error code: 1020" + strings.Repeat("x", 2*TransportReadLimit)
+	var read int
+	var matched []string
+	body := strings.NewReader(page)
+	client := &http.Client{Transport: WrapTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Header:     http.Header{"Server": {"cloudflare"}},
+			Body:       io.NopCloser(body),
+			Request:    req,
+		}, nil
+	}), matcher, func(_ *http.Request, providers []string) {
+		read = len(page) - body.Len()
+		matched = providers
+	})}
+
+	resp, err := client.Get("http://example.com/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"cloudflare"}, matched)
+	require.LessOrEqual(t, read, TransportReadLimit+1, "only a prefix of the body should be read")
+	restored, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, page, string(restored))
+}
+
+func TestWrapTransportReadError(t *testing.T) {
+	matcher, err := NewMatcher("")
+	require.NoError(t, err)
+
+	readErr := errors.New("connection reset")
+	client := &http.Client{Transport: WrapTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(iotest.ErrReader(readErr)),
+			Request:    req,
+		}, nil
+	}), matcher, nil)}
+
+	resp, err := client.Get("http://example.com/")
+	require.NoError(t, err, "detection failures should not fail the request")
+	_, err = io.ReadAll(resp.Body)
+	require.ErrorIs(t, err, readErr)
+	resp.Body.Close()
+}
@@ -0,0 +1,79 @@
+package cleanhttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// Validate checks a rules document for common issues. Besides the
+// errors reported when loading it, such as unknown keys or invalid
+// regular expressions, it reports rules without any condition, which
+// match every response, and inverted status code ranges, which never
+// match. Every issue found is joined into the returned error.
+func Validate(data []byte) error {
+	servicesJSON, err := decodeRules(data, true)
+	if err != nil {
+		return err
+	}
+	if err := checkSchemaVersion(servicesJSON.Version); err != nil {
+		return err
+	}
+
+	m := emptyMatcher(nil)
+	empty := m.newInput(Response{}, http.Header{})
+
+	providers := make([]string, 0, len(servicesJSON.Services))
+	for provider := range servicesJSON.Services {
+		providers = append(providers, provider)
+	}
+	slices.Sort(providers)
+
+	var errs []error
+	for _, provider := range providers {
+		rule, err := compileRule(servicesJSON.Services[provider])
+		if err == nil {
+			err = m.lintRule(empty, rule)
+		}
+		if err != nil {
+			errs = append(errs, &RuleError{Provider: provider, Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// VerifyDefaultRules validates the embedded rules, so that importers can
+// assert their integrity in their own tests
+func VerifyDefaultRules() error {
+	return Validate(defaultRules)
+}
+
+// lintRule checks a compiled rule, and the rules nested in it, for
+// conditions that compile but cannot match as intended
+func (m *Matcher) lintRule(empty *input, rule Rule) error {
+	if rule.HasStatus && rule.StatusMax != 0 && rule.StatusMin > rule.StatusMax {
+		return fmt.Errorf("inverted status code range %d-%d", rule.StatusMin, rule.StatusMax)
+	}
+	checked := rule.On404 != nil
+	for _, signal := range signals {
+		if ok, _ := m.evalSignal(empty, rule, signal, nil); ok {
+			checked = true
+			break
+		}
+	}
+	if !checked {
+		return errors.New("rule has no conditions")
+	}
+	for _, group := range rule.Groups {
+		if err := m.lintRule(empty, group); err != nil {
+			return fmt.Errorf("group: %w", err)
+		}
+	}
+	if rule.On404 != nil {
+		if err := m.lintRule(empty, *rule.On404); err != nil {
+			return fmt.Errorf("on_404: %w", err)
+		}
+	}
+	return nil
+}
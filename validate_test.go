@@ -0,0 +1,70 @@
+package cleanhttp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyDefaultRules(t *testing.T) {
+	require.NoError(t, VerifyDefaultRules())
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		want  []string
+	}{
+		{
+			name:  "valid",
+			rules: `{"services": {"example": {"http_status_code": "403", "http_body": ["denied"]}}}`,
+		},
+		{
+			name:  "empty rule",
+			rules: `{"services": {"example": {"id": "example"}}}`,
+			want:  []string{"compiling rule for example: rule has no conditions"},
+		},
+		{
+			name:  "invalid regex",
+			rules: `{"services": {"example": {"http_body_regex": ["("]}}}`,
+			want:  []string{"compiling rule for example"},
+		},
+		{
+			name:  "inverted range",
+			rules: `{"services": {"example": {"http_status_code": "599-500"}}}`,
+			want:  []string{"compiling rule for example: inverted status code range 599-500"},
+		},
+		{
+			name:  "empty group",
+			rules: `{"services": {"example": {"http_status_code": "403", "match_groups": [{}]}}}`,
+			want:  []string{"compiling rule for example: group: rule has no conditions"},
+		},
+		{
+			name: "every issue",
+			rules: `{"services": {
+				"empty": {},
+				"inverted": {"http_status_code": "599-500"}
+			}}`,
+			want: []string{"compiling rule for empty: rule has no conditions", "compiling rule for inverted: inverted status code range"},
+		},
+		{
+			name:  "unknown key",
+			rules: `{"services": {"example": {"http_bodyy": ["denied"]}}}`,
+			want:  []string{"http_bodyy"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate([]byte(tt.rules))
+			if len(tt.want) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, want := range tt.want {
+				require.ErrorContains(t, err, want)
+			}
+		})
+	}
+}
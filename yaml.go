@@ -0,0 +1,187 @@
+package cleanhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NewMatcherFromYAML creates a Matcher instance with compiled rules from
+// a YAML file using the same structure as the JSON rules
+func NewMatcherFromYAML(rulesPath string, opts ...Option) (*Matcher, error) {
+	data, err := os.ReadFile(rulesPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading rules file: %w", err)
+	}
+	return newMatcherFromYAML(data, opts)
+}
+
+// newMatcherFromYAML creates a Matcher from YAML rules, locating parse
+// errors in the YAML document
+func newMatcherFromYAML(data []byte, opts []Option) (*Matcher, error) {
+	doc, err := yamlToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newMatcher(doc.json.Bytes(), opts)
+	if err != nil {
+		return nil, doc.locateError(err)
+	}
+	return m, nil
+}
+
+// isYAMLPath reports whether the rules file should be parsed as YAML
+func isYAMLPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// yamlJSON is a YAML rules document converted to JSON, remembering the
+// YAML node each JSON value was converted from
+type yamlJSON struct {
+	source []byte
+	json   bytes.Buffer
+	// offsets are the ascending JSON offsets of the values in nodes
+	offsets []int
+	nodes   []*yaml.Node
+}
+
+// yamlToJSON converts YAML rules into their JSON equivalent so both
+// formats go through the same decoding and compilation path. Unquoted
+// status codes, such as http_status_code: 503, are converted to the
+// strings the JSON rules use.
+func yamlToJSON(data []byte) (*yamlJSON, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("parsing rules YAML: %w", err)
+	}
+	doc := &yamlJSON{source: data}
+	if len(root.Content) == 0 {
+		doc.json.WriteString("null")
+		return doc, nil
+	}
+	if err := doc.encode(root.Content[0], ""); err != nil {
+		return nil, fmt.Errorf("converting rules YAML: %w", err)
+	}
+	return doc, nil
+}
+
+// encode writes the node as JSON. key is the mapping key the node is
+// the value of.
+func (d *yamlJSON) encode(node *yaml.Node, key string) error {
+	d.offsets = append(d.offsets, d.json.Len())
+	d.nodes = append(d.nodes, node)
+
+	switch node.Kind {
+	case yaml.AliasNode:
+		return d.encode(node.Alias, key)
+	case yaml.SequenceNode:
+		d.json.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				d.json.WriteByte(',')
+			}
+			if err := d.encode(item, ""); err != nil {
+				return err
+			}
+		}
+		d.json.WriteByte(']')
+		return nil
+	case yaml.MappingNode:
+		d.json.WriteByte('{')
+		first := true
+		if err := d.encodePairs(node, &first); err != nil {
+			return err
+		}
+		d.json.WriteByte('}')
+		return nil
+	case yaml.ScalarNode:
+		var value any = node.Value
+		switch tag := node.ShortTag(); {
+		case tag == "!!int" && key == "http_status_code":
+		case tag == "!!null":
+			value = nil
+		case tag == "!!bool" || tag == "!!int" || tag == "!!float":
+			if err := node.Decode(&value); err != nil {
+				return err
+			}
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		d.json.Write(encoded)
+		return nil
+	}
+	return fmt.Errorf("line %d: unsupported YAML node", node.Line)
+}
+
+// encodePairs writes the key/value pairs of a mapping, those merged in
+// with << first so that the mapping's own keys override them
+func (d *yamlJSON) encodePairs(node *yaml.Node, first *bool) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if key, value := node.Content[i], node.Content[i+1]; key.ShortTag() == "!!merge" {
+			merged := []*yaml.Node{value}
+			if value.Kind == yaml.SequenceNode {
+				merged = value.Content
+			}
+			for _, mapping := range merged {
+				if mapping.Kind == yaml.AliasNode {
+					mapping = mapping.Alias
+				}
+				if err := d.encodePairs(mapping, first); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.ShortTag() == "!!merge" {
+			continue
+		}
+		if !*first {
+			d.json.WriteByte(',')
+		}
+		*first = false
+		encoded, err := json.Marshal(key.Value)
+		if err != nil {
+			return err
+		}
+		d.json.Write(encoded)
+		d.json.WriteByte(':')
+		if err := d.encode(value, key.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// locateError moves the position of a parse error of the converted JSON
+// to the YAML node the failing value was converted from
+func (d *yamlJSON) locateError(err error) error {
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) || parseErr.offset < 0 {
+		return err
+	}
+	// The error offset follows the start of the failing value
+	i := sort.SearchInts(d.offsets, int(parseErr.offset)) - 1
+	if i < 0 {
+		return err
+	}
+	node := d.nodes[i]
+	lineStart := 0
+	for line := 1; line < node.Line; line++ {
+		lineStart += bytes.IndexByte(d.source[lineStart:], '\n') + 1
+	}
+	parseErr.Line, parseErr.Column, parseErr.Snippet = locate(d.source, lineStart+node.Column-1)
+	parseErr.format = "YAML"
+	return err
+}
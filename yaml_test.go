@@ -0,0 +1,64 @@
+package cleanhttp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMatcherFromYAML(t *testing.T) {
+	jsonMatcher, err := NewMatcher("testdata/rules.json")
+	require.NoError(t, err)
+
+	yamlMatcher, err := NewMatcherFromYAML("testdata/rules.yaml")
+	require.NoError(t, err)
+	require.Equal(t, jsonMatcher.rules, yamlMatcher.rules)
+
+	extMatcher, err := NewMatcher("testdata/rules.yaml")
+	require.NoError(t, err)
+	require.Equal(t, jsonMatcher.rules, extMatcher.rules)
+
+	resp := Response{
+		StatusCode: 503,
+		Headers:    map[string]string{"server": "cloudflare"},
+		Body:       "error code: 1020",
+	}
+	require.Equal(t, jsonMatcher.Match(resp), yamlMatcher.Match(resp))
+}
+
+func TestYAMLNumericStatusCode(t *testing.T) {
+	doc, err := yamlToJSON([]byte(`services:
+  maintenance:
+    http_status_code: 503
+    http_body: ["maintenance"]
+  redirection:
+    http_status_code: 301
+    check_redirect:
+      target_ports: [80, 443]
+`))
+	require.NoError(t, err)
+	matcher, err := newMatcher(doc.json.Bytes(), nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"maintenance"}, matcher.Match(Response{StatusCode: 503, Body: "maintenance"}))
+	require.Equal(t, []int{80, 443}, matcher.rules["redirection"].RedirectCheck.TargetPorts)
+}
+
+func TestYAMLParseErrorPosition(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`services:
+  cloudflare:
+    http_header:
+      Server: cloudflare
+    http_body: 5
+`), 0o600))
+
+	_, err := NewMatcher(path)
+	var parseErr *ParseError
+	require.ErrorAs(t, err, &parseErr)
+	require.Equal(t, 5, parseErr.Line)
+	require.Equal(t, 16, parseErr.Column)
+	require.Equal(t, "http_body: 5", parseErr.Snippet)
+	require.ErrorContains(t, err, "parsing rules YAML: line 5, column 16")
+}